@@ -0,0 +1,162 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// apiHandler serves the daemon's control API. Every request must be authenticated with the configured API token.
+func (d *Daemon) apiHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/refresh", d.handleRefresh)
+	mux.HandleFunc("/pause", d.handlePause)
+	mux.HandleFunc("/resume", d.handleResume)
+	mux.HandleFunc("/status", d.handleStatus)
+	mux.HandleFunc("/override", d.handleOverride)
+	return requireToken(d.cfg.Daemon.APIToken, mux)
+}
+
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if len(auth) < 7 || !strings.EqualFold(auth[:7], "bearer ") ||
+			subtle.ConstantTimeCompare([]byte(auth[7:]), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("invalid or missing API token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// daemonStatus is the daemon's state, as returned by the status endpoint.
+type daemonStatus struct {
+	Observation *WeatherResponse       `json:"observation"`
+	Status      *ChangeUserStatusInput `json:"status"`
+	Override    *Override              `json:"override,omitempty"`
+	NextRun     time.Time              `json:"next_run"`
+	PausedUntil *time.Time             `json:"paused_until,omitempty"`
+}
+
+func (d *Daemon) status(now time.Time) daemonStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ds := daemonStatus{
+		Status:  d.last,
+		NextRun: d.nextRun,
+	}
+	if d.lastUpdate != nil {
+		ds.Observation = &d.lastUpdate.Observation
+	}
+	if d.override != nil && now.Before(d.override.ExpiresAt) {
+		ds.Override = d.override
+	}
+	if now.Before(d.pausedUntil) {
+		pausedUntil := d.pausedUntil
+		ds.PausedUntil = &pausedUntil
+	}
+	return ds
+}
+
+func (d *Daemon) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	if err := d.update(r.Context()); err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, d.status(time.Now()))
+}
+
+func (d *Daemon) handlePause(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	dur, err := time.ParseDuration(r.URL.Query().Get("for"))
+	if err != nil || dur <= 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("expected positive duration in \"for\" parameter, e.g. for=2h"))
+		return
+	}
+	d.pause(time.Now().Add(dur))
+	writeJSON(w, http.StatusOK, d.status(time.Now()))
+}
+
+func (d *Daemon) handleResume(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	d.pause(time.Time{})
+	writeJSON(w, http.StatusOK, d.status(time.Now()))
+}
+
+func (d *Daemon) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	writeJSON(w, http.StatusOK, d.status(time.Now()))
+}
+
+func (d *Daemon) handleOverride(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPut) {
+		return
+	}
+
+	var req struct {
+		Emoji   string `json:"emoji"`
+		Message string `json:"message"`
+		For     string `json:"for"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("error parsing request: %v", err))
+		return
+	}
+	if req.Emoji == "" && req.Message == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("emoji or message is required"))
+		return
+	}
+
+	dur := time.Duration(d.cfg.ExpirationTime) * time.Minute
+	if req.For != "" {
+		var err error
+		if dur, err = time.ParseDuration(req.For); err != nil || dur <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("expected positive duration in \"for\", e.g. \"2h\""))
+			return
+		}
+	}
+
+	o := Override{
+		Emoji:     req.Emoji,
+		Message:   req.Message,
+		ExpiresAt: time.Now().Add(dur),
+	}
+	if err := d.setOverride(r.Context(), o); err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, d.status(time.Now()))
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": redactor.Redact(err.Error())})
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDaemon_api(t *testing.T) {
+	var setStatuses []string
+	gh := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Variables struct {
+				Status ChangeUserStatusInput `json:"status"`
+			} `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		setStatuses = append(setStatuses, req.Variables.Status.Emoji+" "+req.Variables.Status.Message)
+		w.Write([]byte(`{"data":{"changeUserStatus":{"status":{"id":"1","updatedAt":"` + time.Now().UTC().Format(time.RFC3339) + `"}}}}`))
+	}))
+	defer gh.Close()
+
+	owm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"cod":200,"name":"Berlin","weather":[{"id":800,"icon":"01d"}],"main":{"temp":9.07}}`))
+	}))
+	defer owm.Close()
+
+	var cfg Config
+	cfg.StateDir = t.TempDir()
+	cfg.ExpirationTime = 30
+	cfg.Daemon.APIToken = "api-token"
+
+	d := NewDaemon(cfg, NewOWMClient(owm.URL+"/weather?appid={api-key}", "key", nil), NewGitHubClient(gh.URL, "token", nil))
+	h := d.Handler()
+
+	do := func(method, target, body, token string) (int, daemonStatus) {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		var ds daemonStatus
+		json.NewDecoder(rec.Body).Decode(&ds)
+		return rec.Code, ds
+	}
+
+	if code, _ := do("GET", "/status", "", ""); code != http.StatusUnauthorized {
+		t.Errorf("GET /status without token: want %d, got %d", http.StatusUnauthorized, code)
+	}
+	if code, _ := do("GET", "/status", "", "wrong"); code != http.StatusUnauthorized {
+		t.Errorf("GET /status with wrong token: want %d, got %d", http.StatusUnauthorized, code)
+	}
+	if code, _ := do("GET", "/refresh", "", "api-token"); code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /refresh: want %d, got %d", http.StatusMethodNotAllowed, code)
+	}
+
+	code, ds := do("POST", "/refresh", "", "api-token")
+	if code != http.StatusOK {
+		t.Fatalf("POST /refresh: want %d, got %d", http.StatusOK, code)
+	}
+	if ds.Observation == nil || ds.Observation.Name != "Berlin" || ds.Status == nil || ds.Status.Message != "Berlin, +9°" {
+		t.Errorf("POST /refresh: unexpected status %+v", ds)
+	}
+
+	if code, _ := do("POST", "/pause", "", "api-token"); code != http.StatusBadRequest {
+		t.Errorf("POST /pause without duration: want %d, got %d", http.StatusBadRequest, code)
+	}
+	code, ds = do("POST", "/pause?for=2h", "", "api-token")
+	if code != http.StatusOK || ds.PausedUntil == nil {
+		t.Errorf("POST /pause: want paused status, got %d %+v", code, ds)
+	}
+	if reason := d.skipReason(time.Now()); reason != "paused" {
+		t.Errorf("Daemon.skipReason: want paused, got %q", reason)
+	}
+	code, ds = do("POST", "/resume", "", "api-token")
+	if code != http.StatusOK || ds.PausedUntil != nil {
+		t.Errorf("POST /resume: want resumed status, got %d %+v", code, ds)
+	}
+
+	code, ds = do("PUT", "/override", `{"emoji":":coffee:","message":"On a break","for":"15m"}`, "api-token")
+	if code != http.StatusOK || ds.Override == nil || ds.Status.Message != "On a break" {
+		t.Errorf("PUT /override: unexpected response %d %+v", code, ds)
+	}
+	if reason := d.skipReason(time.Now()); reason != "overridden" {
+		t.Errorf("Daemon.skipReason: want overridden, got %q", reason)
+	}
+	if reason := d.skipReason(time.Now().Add(time.Hour)); reason != "" {
+		t.Errorf("Daemon.skipReason: want no reason after override expired, got %q", reason)
+	}
+
+	want := []string{":sunny: Berlin, +9°", ":coffee: On a break"}
+	if strings.Join(setStatuses, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Daemon: want statuses %q, got %q", want, setStatuses)
+	}
+}
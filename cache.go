@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	// defaultCacheTTL matches the rate, at which OpenWeather updates its data.
+	defaultCacheTTL = 10 * time.Minute
+	// weatherCacheDir is the cache's directory in the state directory.
+	weatherCacheDir = "weather-cache"
+)
+
+// WeatherCache keeps the weather providers' responses on disk, so the invocations of the program, that ask
+// for the same location, share them. Every entry is a file, replaced atomically, so concurrent invocations never
+// see it partially written.
+type WeatherCache struct {
+	dir string
+	ttl time.Duration
+}
+
+func NewWeatherCache(dir string, ttl time.Duration) *WeatherCache {
+	return &WeatherCache{
+		dir: dir,
+		ttl: ttl,
+	}
+}
+
+// CacheEntry is a cached provider response.
+type CacheEntry struct {
+	Provider string `json:"provider"`
+	// Request is the provider's request without the location and the credentials, e.g. the endpoint with its
+	// units and language, so the responses to the differently configured requests don't share the entry.
+	Request   string    `json:"request,omitempty"`
+	Location  string    `json:"location"`
+	FetchedAt time.Time `json:"fetched_at"`
+	// ExpiresAt is the time the entry must be revalidated with the provider.
+	ExpiresAt    time.Time `json:"expires_at"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	// Date is the response's Date header, received at FetchedAt, so the cache hits keep the server's clock known.
+	Date string          `json:"date,omitempty"`
+	Body json.RawMessage `json:"body"`
+}
+
+// ObserveClock updates the server's clock from the entry's Date, as if its response was received again.
+func (e *CacheEntry) ObserveClock(c *Clock) {
+	if e.Date != "" {
+		c.Observe(http.Header{"Date": {e.Date}}, e.FetchedAt)
+	}
+}
+
+// Fresh reports whether the entry can be used without asking the provider.
+func (e *CacheEntry) Fresh(now time.Time) bool {
+	return now.Before(e.ExpiresAt)
+}
+
+// normalizeLocation makes the different spellings of the same location query share the cache entry,
+// e.g. "Berlin, DE" and "berlin,de".
+func normalizeLocation(location string) string {
+	parts := strings.Split(strings.ToLower(location), ",")
+	for i, p := range parts {
+		parts[i] = strings.Join(strings.Fields(p), " ")
+	}
+	return strings.Join(parts, ",")
+}
+
+func (c *WeatherCache) path(provider, request, location string) string {
+	sum := sha256.Sum256([]byte(provider + ":" + request + ":" + normalizeLocation(location)))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:8])+".json")
+}
+
+// Get returns the cached response of the provider to the request for the location, fresh or not, or nil if there
+// is none.
+func (c *WeatherCache) Get(provider, request, location string) (*CacheEntry, error) {
+	data, err := os.ReadFile(c.path(provider, request, location))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading weather cache: %v", err)
+	}
+	var e CacheEntry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("error parsing weather cache entry: %v", err)
+	}
+	return &e, nil
+}
+
+// Put caches the provider's response to the request, received with the header, unless the provider forbids
+// to store it. The entry expires after the cache's TTL, or earlier, if the response's Cache-Control says so.
+func (c *WeatherCache) Put(provider, request, location string, header http.Header, body []byte, now time.Time) error {
+	e := &CacheEntry{
+		Provider:     provider,
+		Request:      request,
+		Location:     normalizeLocation(location),
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+		Body:         body,
+	}
+	if !c.refresh(e, header, now) {
+		return nil
+	}
+	return c.write(e)
+}
+
+// Revalidate extends the life of the entry, after the provider confirmed, it's not modified.
+func (c *WeatherCache) Revalidate(e *CacheEntry, header http.Header, now time.Time) error {
+	if etag := header.Get("ETag"); etag != "" {
+		e.ETag = etag
+	}
+	if !c.refresh(e, header, now) {
+		return nil
+	}
+	return c.write(e)
+}
+
+// refresh sets the entry's expiration time, according to the response's Cache-Control. It reports false,
+// if the response must not be stored.
+func (c *WeatherCache) refresh(e *CacheEntry, header http.Header, now time.Time) bool {
+	ttl := c.ttl
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-store":
+			return false
+		case directive == "no-cache":
+			ttl = 0
+		case strings.HasPrefix(directive, "max-age="):
+			if secs, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age=")); err == nil {
+				if maxAge := time.Duration(secs) * time.Second; maxAge < ttl {
+					ttl = maxAge
+				}
+			}
+		}
+	}
+	e.FetchedAt = now
+	e.ExpiresAt = now.Add(ttl)
+	e.Date = header.Get("Date")
+	return true
+}
+
+func (c *WeatherCache) write(e *CacheEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(c.path(e.Provider, e.Request, e.Location), data, 0600); err != nil {
+		return fmt.Errorf("error writing weather cache: %v", err)
+	}
+	return nil
+}
+
+// Entries returns all cached entries, ordered by provider and location.
+func (c *WeatherCache) Entries() ([]CacheEntry, error) {
+	paths, err := filepath.Glob(filepath.Join(c.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var entries []CacheEntry
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading weather cache: %v", err)
+		}
+		var e CacheEntry
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("error parsing weather cache entry %q: %v", filepath.Base(path), err)
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Provider != entries[j].Provider {
+			return entries[i].Provider < entries[j].Provider
+		}
+		return entries[i].Location < entries[j].Location
+	})
+	return entries, nil
+}
+
+// Purge removes the cached entries for the location, or all entries if location is empty. It returns the number
+// of removed entries.
+func (c *WeatherCache) Purge(location string) (int, error) {
+	paths, err := filepath.Glob(filepath.Join(c.dir, "*.json"))
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for _, path := range paths {
+		if location != "" {
+			var e CacheEntry
+			data, err := os.ReadFile(path)
+			if err == nil && json.Unmarshal(data, &e) == nil && e.Location != normalizeLocation(location) {
+				continue
+			}
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// runCache inspects or purges the weather cache.
+func runCache(ctx context.Context, args []string) error {
+	action := "list"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
+
+	var (
+		configPath string
+		location   string
+	)
+
+	flags := newFlagSet("cache", "List the cached weather (cache list), or remove it (cache purge)")
+	flags.StringVar(&configPath, "configuration", "config.yaml", "Path to configuration file")
+	flags.StringVar(&location, "location", "", "Purge only the weather for this location query")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadCommandConfig(configPath, false)
+	if err != nil {
+		return err
+	}
+	cache := newWeatherCache(cfg)
+
+	switch action {
+	case "list":
+		entries, err := cache.Entries()
+		if err != nil {
+			return err
+		}
+		return printCacheEntries(os.Stdout, entries, time.Now())
+	case "purge":
+		n, err := cache.Purge(location)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("removed %d cache entries\n", n)
+		return nil
+	}
+	return fmt.Errorf("unknown cache action %q, expected list or purge", action)
+}
+
+func printCacheEntries(w io.Writer, entries []CacheEntry, now time.Time) error {
+	if len(entries) == 0 {
+		_, err := fmt.Fprintln(w, "weather cache is empty")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROVIDER\tLOCATION\tFETCHED\tEXPIRES\tSTATE")
+	for _, e := range entries {
+		state := "fresh"
+		if !e.Fresh(now) {
+			state = "stale"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", e.Provider, e.Location,
+			e.FetchedAt.Local().Format(time.RFC3339), e.ExpiresAt.Local().Format(time.RFC3339), state)
+	}
+	return tw.Flush()
+}
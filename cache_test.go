@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNormalizeLocation(t *testing.T) {
+	cases := []struct {
+		location string
+		want     string
+	}{
+		{"Berlin,DE", "berlin,de"},
+		{" Berlin , DE ", "berlin,de"},
+		{"Saint  Paul, MN,US", "saint paul,mn,us"},
+	}
+
+	for n, tc := range cases {
+		t.Run(fmt.Sprintf("case=%d", n), func(t *testing.T) {
+			if got := normalizeLocation(tc.location); got != tc.want {
+				t.Errorf("normalizeLocation: want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestOWMClient_Weather_cache(t *testing.T) {
+	const weather = `{"cod":200,"name":"Berlin","weather":[{"id":800,"icon":"01d"}],"main":{"temp":9.07}}`
+
+	cases := []struct {
+		name         string
+		header       map[string]string
+		ttl          time.Duration
+		wantRequests int
+		wantCached   bool
+	}{
+		{"fresh", nil, time.Hour, 1, true},
+		{"expired", nil, 0, 2, true},
+		{"revalidated", map[string]string{"ETag": `"v1"`}, 0, 2, true},
+		{"max-age", map[string]string{"Cache-Control": "max-age=0"}, time.Hour, 2, true},
+		{"no-store", map[string]string{"Cache-Control": "no-store"}, time.Hour, 2, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests, notModified int
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				for k, v := range tc.header {
+					w.Header().Set(k, v)
+				}
+				if etag := tc.header["ETag"]; etag != "" && r.Header.Get("If-None-Match") == etag {
+					notModified++
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				w.Write([]byte(weather))
+			}))
+			defer ts.Close()
+
+			cache := NewWeatherCache(t.TempDir(), tc.ttl)
+			owm := NewOWMClient(ts.URL+"/weather?appid={api-key}", "key", nil)
+			owm.cache = cache
+
+			for _, query := range []string{"Berlin,DE", "berlin, de"} {
+				wr, err := owm.Weather(context.Background(), query)
+				if err != nil {
+					t.Fatalf("OWMClient.Weather: unexpected error %v", err)
+				}
+				if want := "Berlin"; wr.Name != want {
+					t.Errorf("OWMClient.Weather: want %q, got %q", want, wr.Name)
+				}
+			}
+
+			if requests != tc.wantRequests {
+				t.Errorf("OWMClient.Weather: want %d requests, got %d", tc.wantRequests, requests)
+			}
+			if tc.header["ETag"] != "" && notModified != 1 {
+				t.Errorf("OWMClient.Weather: want conditional request, got %d", notModified)
+			}
+
+			entries, err := cache.Entries()
+			if err != nil {
+				t.Fatalf("WeatherCache.Entries: %v", err)
+			}
+			if got := len(entries) == 1; got != tc.wantCached {
+				t.Errorf("WeatherCache.Entries: want cached %v, got %d entries", tc.wantCached, len(entries))
+			}
+		})
+	}
+}
+
+func TestOWMClient_Weather_cacheUnits(t *testing.T) {
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Query().Get("units"))
+		temp := "9.07"
+		if r.URL.Query().Get("units") == "imperial" {
+			temp = "48.33"
+		}
+		w.Write([]byte(`{"cod":200,"name":"Berlin","weather":[{"id":800,"icon":"01d"}],"main":{"temp":` + temp + `}}`))
+	}))
+	defer ts.Close()
+
+	// The responses in the other units don't share the cache entry, even while it's fresh.
+	cache := NewWeatherCache(t.TempDir(), time.Hour)
+	for _, tc := range []struct {
+		endpoint string
+		want     float64
+	}{
+		{"/weather?appid={api-key}&units=metric", 9.07},
+		{"/weather?appid={api-key}&units=imperial", 48.33},
+		{"/weather?units=metric&appid={api-key}", 9.07},
+	} {
+		owm := NewOWMClient(ts.URL+tc.endpoint, "key", nil)
+		owm.cache = cache
+		wr, err := owm.Weather(context.Background(), "Berlin,DE")
+		if err != nil {
+			t.Fatalf("OWMClient.Weather: unexpected error %v", err)
+		}
+		if wr.Main.Temp != tc.want {
+			t.Errorf("OWMClient.Weather(%s): want temperature %v, got %v", tc.endpoint, tc.want, wr.Main.Temp)
+		}
+	}
+
+	if want := []string{"metric", "imperial"}; fmt.Sprint(requests) != fmt.Sprint(want) {
+		t.Errorf("OWMClient.Weather: want requests in units %v, got %v", want, requests)
+	}
+}
+
+func TestOWMClient_Weather_cacheFetchedAt(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"cod":200,"name":"Berlin","weather":[{"id":800,"icon":"01d"}],"main":{"temp":9.07}}`))
+	}))
+	defer ts.Close()
+
+	// The cached weather keeps the time it was fetched, while it's fresh, and when it's expired, but the budget
+	// is exhausted.
+	for _, ttl := range []time.Duration{time.Hour, 0} {
+		t.Run(fmt.Sprintf("ttl=%s", ttl), func(t *testing.T) {
+			owm := NewOWMClient(ts.URL+"/weather?appid={api-key}", "key", nil)
+			owm.cache = NewWeatherCache(t.TempDir(), ttl)
+
+			fetched, err := owm.Weather(context.Background(), "Berlin,DE")
+			if err != nil {
+				t.Fatalf("OWMClient.Weather: unexpected error %v", err)
+			}
+			if fetched.FetchedAt.IsZero() {
+				t.Fatal("OWMClient.Weather: want fetch time, got zero")
+			}
+
+			owm.quota = NewQuota("owm", t.TempDir(), 1, 1)
+			if err := owm.quota.Take(time.Now()); err != nil {
+				t.Fatalf("Quota.Take: %v", err)
+			}
+			cached, err := owm.Weather(context.Background(), "Berlin,DE")
+			if err != nil {
+				t.Fatalf("OWMClient.Weather: unexpected error %v", err)
+			}
+			if !cached.FetchedAt.Equal(fetched.FetchedAt) {
+				t.Errorf("OWMClient.Weather: want fetched at %s, got %s", fetched.FetchedAt, cached.FetchedAt)
+			}
+		})
+	}
+}
+
+func TestOWMClient_Weather_cacheClock(t *testing.T) {
+	defer func(c *Clock) { serverClock = c }(serverClock)
+
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Date", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+		w.Write([]byte(`{"cod":200,"name":"Berlin","weather":[{"id":800,"icon":"01d"}],"main":{"temp":9.07}}`))
+	}))
+	defer ts.Close()
+
+	cache := NewWeatherCache(t.TempDir(), time.Hour)
+	for i := 0; i < 2; i++ {
+		// Every run of the program starts with the unknown offset.
+		serverClock = &Clock{}
+		owm := NewOWMClient(ts.URL+"/weather?appid={api-key}", "key", nil)
+		owm.cache = cache
+		if _, err := owm.Weather(context.Background(), "Berlin,DE"); err != nil {
+			t.Fatalf("OWMClient.Weather: unexpected error %v", err)
+		}
+		offset, known := serverClock.Offset()
+		if !known || offset > -59*time.Minute || offset < -61*time.Minute {
+			t.Errorf("Clock.Offset: want about -1h after run %d, got %v (known %v)", i, offset, known)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("OWMClient.Weather: want 1 request, got %d", requests)
+	}
+}
+
+func TestWeatherCache_Purge(t *testing.T) {
+	cache := NewWeatherCache(t.TempDir(), time.Hour)
+	now := time.Now()
+	for _, location := range []string{"Berlin,DE", "Paris,FR"} {
+		if err := cache.Put("owm", "", location, http.Header{}, []byte(`{}`), now); err != nil {
+			t.Fatalf("WeatherCache.Put: %v", err)
+		}
+	}
+
+	if n, err := cache.Purge("berlin, de"); err != nil || n != 1 {
+		t.Errorf("WeatherCache.Purge: want 1 entry removed, got %d, %v", n, err)
+	}
+	if e, _ := cache.Get("owm", "", "Paris,FR"); e == nil {
+		t.Errorf("WeatherCache.Get: want entry for other location, got nil")
+	}
+	if n, err := cache.Purge(""); err != nil || n != 1 {
+		t.Errorf("WeatherCache.Purge: want 1 entry removed, got %d, %v", n, err)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// serverClock is the time of the API servers, as seen in their responses.
+var serverClock = &Clock{}
+
+// Clock estimates the offset of the local clock from the servers' time, using the Date headers of HTTP responses.
+// It lets the program work on hosts with wrong clocks, e.g. Raspberry Pi without RTC or NTP.
+type Clock struct {
+	mu     sync.Mutex
+	offset time.Duration
+	known  bool
+	warned bool
+}
+
+// Observe updates the offset from the response's Date header, received at local time receivedAt.
+func (c *Clock) Observe(header http.Header, receivedAt time.Time) {
+	date, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		return
+	}
+	// Date header has a second precision, thus the server's time is somewhere within the second after the date.
+	offset := date.Add(500 * time.Millisecond).Sub(receivedAt)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.offset = offset
+	c.known = true
+
+	if !c.warned && (offset > maxClockSkew || offset < -maxClockSkew) {
+		c.warned = true
+		slog.Warn("local clock differs from servers' time, correcting for it", "offset", offset.Round(time.Second))
+	}
+}
+
+// Offset returns the estimated difference between the servers' and the local time.
+func (c *Clock) Offset() (offset time.Duration, known bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.offset, c.known
+}
+
+// Now returns the current time, corrected by the estimated offset. Until the offset is known, it returns local time.
+func (c *Clock) Now() time.Time {
+	offset, _ := c.Offset()
+	return time.Now().Add(offset)
+}
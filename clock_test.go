@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClock_Observe(t *testing.T) {
+	now := time.Date(2020, 4, 23, 12, 28, 34, 0, time.UTC)
+
+	c := &Clock{}
+	if _, known := c.Offset(); known {
+		t.Fatal("Clock.Offset: want unknown offset before any observations")
+	}
+
+	c.Observe(http.Header{"Date": {"garbage"}}, now)
+	if _, known := c.Offset(); known {
+		t.Fatal("Clock.Offset: want unknown offset after invalid Date")
+	}
+
+	h := http.Header{"Date": {now.Add(-time.Hour).Format(http.TimeFormat)}}
+	c.Observe(h, now)
+	offset, known := c.Offset()
+	if want := -time.Hour + 500*time.Millisecond; !known || offset != want {
+		t.Errorf("Clock.Offset: want %v, got %v (known %v)", want, offset, known)
+	}
+}
+
+func TestGitHubClient_ChangeUserStatus_clockSkew(t *testing.T) {
+	defer func(c *Clock) { serverClock = c }(serverClock)
+	serverClock = &Clock{}
+
+	// GitHub's time is an hour behind the local clock.
+	serverNow := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+
+	cases := []struct {
+		name      string
+		updatedAt time.Time
+		wantErr   bool
+	}{
+		{"updated", serverNow, false},
+		{"not updated", serverNow.Add(-10 * time.Minute), true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Date", serverNow.Format(http.TimeFormat))
+				w.Write([]byte(`{"data":{"changeUserStatus":{"status":{"id":"1","updatedAt":"` + tc.updatedAt.Format(time.RFC3339) + `"}}}}`))
+			}))
+			defer ts.Close()
+
+			gh := NewGitHubClient(ts.URL, "token", nil)
+			_, err := gh.ChangeUserStatus(context.Background(), ChangeUserStatusInput{Message: "Berlin, +9°"})
+			if (err != nil) != tc.wantErr {
+				t.Errorf("GitHubClient.ChangeUserStatus: want error %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,463 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const progName = "github-weather"
+
+// commands lists the program's subcommands, as printed in the usage.
+var commands = []struct {
+	name    string
+	summary string
+}{
+	{"set", "Set user's status to the current weather (default)"},
+	{"clear", "Clear user's status, if it was set by the program"},
+	{"daemon", "Keep user's status updated with the current weather"},
+	{"show", "Show user's current status"},
+	{"preview", "Render the status for the current weather, without setting it"},
+	{"validate", "Validate the configuration file"},
+	{"doctor", "Check the configuration, the API credentials and the environment"},
+	{"cache", "List or purge the cached weather"},
+	{"login", "Authorize the program on GitHub and store the token"},
+	{"logout", "Delete the token, stored by login"},
+}
+
+// run executes the subcommand, named by the first argument. When the subcommand is omitted, the program
+// sets user's status, as it did before the subcommands were introduced.
+func run(ctx context.Context, args []string) error {
+	name := "set"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	switch name {
+	case "set":
+		return runSet(ctx, args)
+	case "clear":
+		return runClear(ctx, args)
+	case "daemon":
+		return runDaemon(ctx, args)
+	case "show":
+		return runShow(ctx, args)
+	case "preview":
+		return runPreview(ctx, args)
+	case "validate":
+		return runValidate(ctx, args)
+	case "doctor":
+		return runDoctor(ctx, args)
+	case "cache":
+		return runCache(ctx, args)
+	case "login":
+		return runLogin(ctx, args)
+	case "logout":
+		return runLogout(ctx, args)
+	case "help":
+		printCommands(os.Stdout)
+		return nil
+	}
+	return fmt.Errorf("unknown command %q, run %s help for the list of commands", name, progName)
+}
+
+func newFlagSet(name, summary string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Usage = func() {
+		out := flags.Output()
+		fmt.Fprintf(out, "Usage: %s %s [flags]\n\n%s.\n", progName, name, summary)
+		if name == "set" {
+			fmt.Fprintln(out)
+			printCommands(out)
+		}
+		fmt.Fprintf(out, "\nFlags:\n")
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+func printCommands(w io.Writer) {
+	fmt.Fprintf(w, "Commands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+}
+
+func runSet(ctx context.Context, args []string) error {
+	var (
+		debug      bool
+		dryRun     bool
+		configPath string
+	)
+
+	flags := newFlagSet("set", "Set user's status to the current weather")
+	flags.BoolVar(&debug, "debug", false, "Enable debug logging")
+	flags.BoolVar(&dryRun, "dry-run", false, "Print the status instead of setting it on GitHub")
+	flags.StringVar(&configPath, "configuration", "config.yaml", "Path to configuration file")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadCommandConfig(configPath, debug, validateConfig)
+	if err != nil {
+		return err
+	}
+
+	owm, err := newOWMClient(cfg)
+	if err != nil {
+		return err
+	}
+	gh, err := newGitHubClient(ctx, cfg, debug)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		wr, err := owm.Weather(ctx, cfg.OWM.Query)
+		if err != nil {
+			return err
+		}
+		logWeather(ctx, wr)
+		status := newStatus(cfg, wr, serverClock.Now())
+		if status.OrganizationID, err = resolveOrganization(ctx, cfg, gh); err != nil {
+			return err
+		}
+		return printStatus(os.Stdout, status)
+	}
+
+	ctx = withRunID(ctx)
+
+	var hb *Heartbeat
+	if cfg.Health.HeartbeatURL != "" {
+		httpClient, err := newHTTPClient(cfg.Health.HTTP)
+		if err != nil {
+			return fmt.Errorf("error configuring heartbeat client: %v", err)
+		}
+		hb = NewHeartbeat(cfg.Health.HeartbeatURL, cfg.Health.HeartbeatMethod, httpClient)
+		hb.Start(ctx)
+	}
+
+	update, err := updateStatus(ctx, cfg, owm, gh)
+	skipped := errors.Is(err, ErrStatusKept)
+	if skipped {
+		err = nil
+	}
+	if hb != nil {
+		hb.Done(ctx, err)
+	}
+	if cfg.Metrics.Pushgateway != "" {
+		if err := pushMetrics(ctx, cfg); err != nil {
+			slog.WarnContext(ctx, "failed to push metrics", "error", err)
+		}
+	}
+	if err != nil || skipped {
+		return err
+	}
+
+	if err := writeStateFile(cfg.StateDir, lastStatusFile, update.Status); err != nil {
+		slog.WarnContext(ctx, "failed to save last status", "error", err)
+	}
+
+	return nil
+}
+
+func runClear(ctx context.Context, args []string) error {
+	var (
+		debug      bool
+		force      bool
+		configPath string
+	)
+
+	flags := newFlagSet("clear", "Clear user's status, if it was set by the program")
+	flags.BoolVar(&debug, "debug", false, "Enable debug logging")
+	flags.BoolVar(&force, "force", false, "Clear the status, even if it wasn't set by the program")
+	flags.StringVar(&configPath, "configuration", "config.yaml", "Path to configuration file")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadCommandConfig(configPath, debug, validateGitHubConfig)
+	if err != nil {
+		return err
+	}
+
+	gh, err := newGitHubClient(ctx, cfg, debug)
+	if err != nil {
+		return err
+	}
+
+	var last *ChangeUserStatusInput
+	if !force {
+		last = new(ChangeUserStatusInput)
+		if err := readStateFile(cfg.StateDir, lastStatusFile, last); errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w: no status was set from %s, use -force to clear the status anyway", errNotOwnStatus, cfg.StateDir)
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if err := clearStatus(ctx, cfg, gh, last); err != nil {
+		return err
+	}
+
+	return removeStateFile(cfg.StateDir, lastStatusFile)
+}
+
+func runDaemon(ctx context.Context, args []string) error {
+	var (
+		debug      bool
+		configPath string
+	)
+
+	flags := newFlagSet("daemon", "Keep user's status updated with the current weather")
+	flags.BoolVar(&debug, "debug", false, "Enable debug logging")
+	flags.StringVar(&configPath, "configuration", "config.yaml", "Path to configuration file")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadCommandConfig(configPath, debug, validateConfig)
+	if err != nil {
+		return err
+	}
+
+	owm, err := newOWMClient(cfg)
+	if err != nil {
+		return err
+	}
+	gh, err := newGitHubClient(ctx, cfg, debug)
+	if err != nil {
+		return err
+	}
+
+	return NewDaemon(cfg, owm, gh).Run(ctx)
+}
+
+func runShow(ctx context.Context, args []string) error {
+	var (
+		debug      bool
+		configPath string
+	)
+
+	flags := newFlagSet("show", "Show user's current status")
+	flags.BoolVar(&debug, "debug", false, "Enable debug logging")
+	flags.StringVar(&configPath, "configuration", "config.yaml", "Path to configuration file")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadCommandConfig(configPath, debug, validateGitHubConfig)
+	if err != nil {
+		return err
+	}
+
+	gh, err := newGitHubClient(ctx, cfg, debug)
+	if err != nil {
+		return err
+	}
+	us, err := gh.UserStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	return printUserStatus(os.Stdout, us, time.Now())
+}
+
+// runPreview renders the status for the current weather, or for a saved observation, without changing it on GitHub.
+func runPreview(ctx context.Context, args []string) error {
+	var (
+		configPath      string
+		observationPath string
+		at              timeFlag
+	)
+
+	flags := newFlagSet("preview", "Render the status for the current weather, without setting it")
+	flags.StringVar(&configPath, "configuration", "config.yaml", "Path to configuration file")
+	flags.StringVar(&observationPath, "observation", "", "Path to a saved OpenWeather API response to use instead of fetching the weather")
+	flags.Var(&at, "at", "Render the status as if it was set at this time (RFC 3339 or 15:04)")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadCommandConfig(configPath, false)
+	if err != nil {
+		return err
+	}
+
+	var wr WeatherResponse
+	if observationPath != "" {
+		wr, err = WeatherFromFile(observationPath)
+	} else if err = validateOWMConfig(cfg); err != nil {
+		err = fmt.Errorf("error validating configuration file: %v", err)
+	} else {
+		var owm *OWMClient
+		if owm, err = newOWMClient(cfg); err == nil {
+			wr, err = owm.Weather(ctx, cfg.OWM.Query)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	now := serverClock.Now()
+	if !at.IsZero() {
+		now = at.Time
+	}
+
+	return printStatus(os.Stdout, newStatus(cfg, wr, now))
+}
+
+func runValidate(ctx context.Context, args []string) error {
+	var configPath string
+
+	flags := newFlagSet("validate", "Validate the configuration file")
+	flags.StringVar(&configPath, "configuration", "config.yaml", "Path to configuration file")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if _, err := loadCommandConfig(configPath, false, validateConfig); err != nil {
+		return err
+	}
+
+	fmt.Printf("configuration file %q is valid\n", configPath)
+
+	return nil
+}
+
+// loadCommandConfig loads the configuration file, checks it with the validate functions and sets up logging.
+func loadCommandConfig(configPath string, debug bool, validate ...func(Config) error) (Config, error) {
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return cfg, err
+	}
+	for _, v := range validate {
+		if err := v(cfg); err != nil {
+			return cfg, fmt.Errorf("error validating configuration file: %v", err)
+		}
+	}
+	if err := setupTracing(cfg); err != nil {
+		return cfg, err
+	}
+	return cfg, setupLogging(os.Stderr, cfg, debug)
+}
+
+// newGitHubClient creates the client of the configured GitHub API. The token of GitHub CLI is read here, so
+// the commands, that don't call GitHub API, don't need it, and it's checked to have the required scope, before
+// it's used.
+func newGitHubClient(ctx context.Context, cfg Config, debug bool) (*GitHubClient, error) {
+	if cfg.GitHub.TokenSource == tokenSourceGH {
+		if err := loadGHToken(&cfg); err != nil {
+			return nil, err
+		}
+		redactor.AddSecret(cfg.GitHub.Token)
+	}
+	httpClient, err := newHTTPClient(cfg.GitHub.HTTP)
+	if err != nil {
+		return nil, fmt.Errorf("error configuring github client: %v", err)
+	}
+	gh := NewGitHubClient(cfg.GitHub.Endpoint, cfg.GitHub.Token, httpClient)
+	if debug {
+		gh.debug = true
+	}
+	if cfg.GitHub.TokenSource == tokenSourceGH {
+		if err := verifyGHToken(ctx, cfg, gh); err != nil {
+			return nil, err
+		}
+	}
+	return gh, nil
+}
+
+func newOWMClient(cfg Config) (*OWMClient, error) {
+	httpClient, err := newHTTPClient(cfg.OWM.HTTP)
+	if err != nil {
+		return nil, fmt.Errorf("error configuring owm client: %v", err)
+	}
+	owm := NewOWMClient(cfg.OWM.Endpoint, cfg.OWM.ApiKey, httpClient)
+	if !cfg.Cache.Disabled {
+		owm.cache = newWeatherCache(cfg)
+	}
+	if !cfg.OWM.Quota.Disabled {
+		owm.quota = NewQuota("owm", cfg.StateDir, cfg.OWM.Quota.PerMinute, cfg.OWM.Quota.PerDay)
+	}
+	if !cfg.OWM.Validation.Disabled {
+		owm.validator = NewWeatherValidator(cfg.OWM.Validation.MaxAge, owmUnits(cfg.OWM.Endpoint))
+	}
+	return owm, nil
+}
+
+// pushMetrics pushes the metrics to the configured Pushgateway.
+func pushMetrics(ctx context.Context, cfg Config) error {
+	httpClient, err := newHTTPClient(cfg.Metrics.HTTP)
+	if err != nil {
+		return fmt.Errorf("error configuring metrics client: %v", err)
+	}
+	return metrics.Push(ctx, httpClient, cfg.Metrics.Pushgateway, cfg.Metrics.Job)
+}
+
+func newWeatherCache(cfg Config) *WeatherCache {
+	return NewWeatherCache(filepath.Join(cfg.StateDir, weatherCacheDir), cfg.Cache.TTL)
+}
+
+// printStatus writes the status input, exactly as it would be sent to GitHub API.
+func printStatus(w io.Writer, status ChangeUserStatusInput) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(status)
+}
+
+func printUserStatus(w io.Writer, us *UserStatus, now time.Time) error {
+	if us == nil {
+		_, err := fmt.Fprintln(w, "no status is set")
+		return err
+	}
+
+	fmt.Fprintf(w, "%s %s\n", us.Emoji, us.Message)
+	fmt.Fprintf(w, "updated at %s\n", us.UpdatedAt.Local().Format(time.RFC3339))
+	if us.ExpiresAt.IsZero() {
+		_, err := fmt.Fprintln(w, "never expires")
+		return err
+	}
+	_, err := fmt.Fprintf(w, "expires at %s (in %s)\n", us.ExpiresAt.Local().Format(time.RFC3339), us.ExpiresAt.Sub(now).Round(time.Second))
+	return err
+}
+
+// timeFlag is a flag.Value, that accepts either a full RFC 3339 timestamp or a time of the current day.
+type timeFlag struct {
+	time.Time
+}
+
+func (f *timeFlag) String() string {
+	if f.IsZero() {
+		return ""
+	}
+	return f.Format(time.RFC3339)
+}
+
+func (f *timeFlag) Set(s string) error {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		f.Time = t
+		return nil
+	}
+	t, err := time.ParseInLocation("15:04", s, time.Local)
+	if err != nil {
+		return fmt.Errorf("expected RFC 3339 timestamp or 15:04 time, got %q", s)
+	}
+	y, m, d := time.Now().Date()
+	f.Time = time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, time.Local)
+	return nil
+}
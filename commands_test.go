@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTimeFlag_Set(t *testing.T) {
+	y, m, d := time.Now().Date()
+	cases := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{"2020-04-23T22:30:00Z", time.Date(2020, 4, 23, 22, 30, 0, 0, time.UTC), false},
+		{"23:30", time.Date(y, m, d, 23, 30, 0, 0, time.Local), false},
+		{"tonight", time.Time{}, true},
+	}
+
+	for n, tc := range cases {
+		t.Run(fmt.Sprintf("case=%d", n), func(t *testing.T) {
+			var f timeFlag
+			err := f.Set(tc.in)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("timeFlag.Set(%q): unexpected error %v", tc.in, err)
+			}
+			if !f.Equal(tc.want) {
+				t.Errorf("timeFlag.Set(%q): want %v, got %v", tc.in, tc.want, f.Time)
+			}
+		})
+	}
+}
+
+func TestPrintUserStatus(t *testing.T) {
+	now := time.Date(2020, 4, 23, 12, 30, 0, 0, time.UTC)
+	cases := []struct {
+		us   *UserStatus
+		want string
+	}{
+		{nil, "no status is set\n"},
+		{
+			&UserStatus{Emoji: ":sunny:", Message: "Berlin, +9°", UpdatedAt: now.Add(-2 * time.Minute)},
+			":sunny: Berlin, +9°\nupdated at " + now.Add(-2*time.Minute).Local().Format(time.RFC3339) + "\nnever expires\n",
+		},
+		{
+			&UserStatus{Emoji: ":sunny:", Message: "Berlin, +9°", UpdatedAt: now, ExpiresAt: now.Add(30 * time.Minute)},
+			":sunny: Berlin, +9°\nupdated at " + now.Local().Format(time.RFC3339) + "\nexpires at " + now.Add(30*time.Minute).Local().Format(time.RFC3339) + " (in 30m0s)\n",
+		},
+	}
+
+	for n, tc := range cases {
+		t.Run(fmt.Sprintf("case=%d", n), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := printUserStatus(&buf, tc.us, now); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tc.want {
+				t.Errorf("printUserStatus: want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
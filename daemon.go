@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// shutdownTimeout limits the time the daemon spends on the cleanup, after it was asked to stop.
+const shutdownTimeout = 10 * time.Second
+
+// Daemon keeps user's status updated, fetching the weather every configured interval.
+type Daemon struct {
+	cfg Config
+	owm *OWMClient
+	gh  *GitHubClient
+
+	// runMu serialises the changes of user's status, made by the scheduled updates and the control API.
+	runMu sync.Mutex
+
+	mu sync.Mutex
+	// last is the status, set by the daemon's most recent successful update.
+	last *ChangeUserStatusInput
+	// lastUpdate is the daemon's most recent successful weather update.
+	lastUpdate  *StatusUpdate
+	nextRun     time.Time
+	pausedUntil time.Time
+	override    *Override
+	startedAt   time.Time
+	// lastSuccess is the time of the daemon's most recent successful change of user's status.
+	lastSuccess time.Time
+	// lastKept is the time of the daemon's most recent update, that kept user's status in degraded mode.
+	lastKept time.Time
+}
+
+// Override is a custom status, that replaces the weather until it expires.
+type Override struct {
+	Emoji     string    `json:"emoji"`
+	Message   string    `json:"message"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func NewDaemon(cfg Config, owm *OWMClient, gh *GitHubClient) *Daemon {
+	return &Daemon{
+		cfg:       cfg,
+		owm:       owm,
+		gh:        gh,
+		startedAt: time.Now(),
+	}
+}
+
+// Run updates the status until the context is canceled. The failed updates are logged and retried on the next tick.
+func (d *Daemon) Run(ctx context.Context) error {
+	if d.cfg.Daemon.Listen != "" {
+		srv := &http.Server{
+			Addr:    d.cfg.Daemon.Listen,
+			Handler: d.Handler(),
+		}
+		go func() {
+			slog.InfoContext(ctx, "listening", "addr", srv.Addr)
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				slog.ErrorContext(ctx, "http server failed", "error", err)
+			}
+		}()
+		defer srv.Close()
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return d.shutdown()
+		case <-timer.C:
+		}
+
+		d.mu.Lock()
+		d.nextRun = time.Now().Add(d.cfg.Daemon.Interval)
+		d.mu.Unlock()
+		timer.Reset(d.cfg.Daemon.Interval)
+
+		if reason := d.skipReason(time.Now()); reason != "" {
+			slog.InfoContext(ctx, "skipping scheduled update", "reason", reason)
+			continue
+		}
+		d.update(ctx)
+	}
+}
+
+// Handler returns the daemon's HTTP handler.
+func (d *Daemon) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	mux.HandleFunc("/healthz", d.handleLive)
+	mux.HandleFunc("/readyz", d.handleReady)
+	if d.cfg.Daemon.APIToken != "" {
+		api := d.apiHandler()
+		for _, path := range []string{"/refresh", "/pause", "/resume", "/status", "/override"} {
+			mux.Handle(path, api)
+		}
+	}
+	return mux
+}
+
+// skipReason explains why the scheduled update must be skipped, or returns empty string.
+func (d *Daemon) skipReason(now time.Time) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if now.Before(d.pausedUntil) {
+		return "paused"
+	}
+	if d.override != nil {
+		if now.Before(d.override.ExpiresAt) {
+			return "overridden"
+		}
+		d.override = nil
+	}
+	return ""
+}
+
+func (d *Daemon) update(ctx context.Context) error {
+	d.runMu.Lock()
+	defer d.runMu.Unlock()
+
+	ctx = withRunID(ctx)
+	update, err := updateStatus(ctx, d.cfg, d.owm, d.gh)
+	if errors.Is(err, ErrStatusKept) {
+		d.mu.Lock()
+		d.lastKept = time.Now()
+		d.mu.Unlock()
+		return err
+	} else if err != nil {
+		slog.ErrorContext(ctx, "failed to update status", "error", err)
+		return err
+	}
+
+	d.mu.Lock()
+	d.last = &update.Status
+	d.lastUpdate = &update
+	d.lastSuccess = time.Now()
+	d.override = nil
+	d.mu.Unlock()
+
+	if err := writeStateFile(d.cfg.StateDir, lastStatusFile, update.Status); err != nil {
+		slog.WarnContext(ctx, "failed to save last status", "error", err)
+	}
+	return nil
+}
+
+// pause stops the scheduled updates until the time.
+func (d *Daemon) pause(until time.Time) {
+	d.mu.Lock()
+	d.pausedUntil = until
+	d.mu.Unlock()
+}
+
+// setOverride replaces user's status with the override, until it expires.
+func (d *Daemon) setOverride(ctx context.Context, o Override) error {
+	d.runMu.Lock()
+	defer d.runMu.Unlock()
+
+	// The override expires by the local clock, but GitHub expires the status by its own.
+	offset, _ := serverClock.Offset()
+	status := ChangeUserStatusInput{
+		ClientMutationID: d.cfg.GitHub.ClientID,
+		Emoji:            o.Emoji,
+		Message:          o.Message,
+		ExpiresAt:        o.ExpiresAt.Add(offset).UTC(),
+	}
+	var err error
+	if status.OrganizationID, err = resolveOrganization(ctx, d.cfg, d.gh); err != nil {
+		return err
+	}
+	if _, err = d.gh.ChangeUserStatus(ctx, status); err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "set gh status override", "emoji", o.Emoji, "message", o.Message, "expires_at", o.ExpiresAt)
+
+	d.mu.Lock()
+	d.last = &status
+	d.lastSuccess = time.Now()
+	d.override = &o
+	d.mu.Unlock()
+
+	if err := writeStateFile(d.cfg.StateDir, lastStatusFile, status); err != nil {
+		slog.WarnContext(ctx, "failed to save last status", "error", err)
+	}
+	return nil
+}
+
+// shutdown clears the status, set by the daemon, if configured with clear_on_exit.
+func (d *Daemon) shutdown() error {
+	d.runMu.Lock()
+	defer d.runMu.Unlock()
+
+	d.mu.Lock()
+	last := d.last
+	d.mu.Unlock()
+
+	if !d.cfg.Daemon.ClearOnExit || last == nil {
+		return nil
+	}
+
+	// The daemon's context is already canceled at this point.
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	err := clearStatus(ctx, d.cfg, d.gh, last)
+	if errors.Is(err, errNotOwnStatus) {
+		slog.InfoContext(ctx, "not clearing gh status", "reason", err)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return removeStateFile(d.cfg.StateDir, lastStatusFile)
+}
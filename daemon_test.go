@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDaemon_shutdown(t *testing.T) {
+	cases := []struct {
+		name        string
+		emoji       string
+		wantCleared bool
+	}{
+		{"own status", ":sunny:", true},
+		{"changed status", ":palm_tree:", false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var cleared bool
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var req struct {
+					Query string `json:"query"`
+				}
+				json.NewDecoder(r.Body).Decode(&req)
+				if strings.Contains(req.Query, "viewer") {
+					w.Write([]byte(`{"data":{"viewer":{"status":{"emoji":"` + tc.emoji + `","message":"Berlin, +9°"}}}}`))
+					return
+				}
+				cleared = true
+				w.Write([]byte(`{"data":{"changeUserStatus":{"status":null}}}`))
+			}))
+			defer ts.Close()
+
+			var cfg Config
+			cfg.StateDir = t.TempDir()
+			cfg.Daemon.ClearOnExit = true
+
+			d := NewDaemon(cfg, nil, NewGitHubClient(ts.URL, "token", nil))
+			d.last = &ChangeUserStatusInput{Emoji: ":sunny:", Message: "Berlin, +9°"}
+
+			if err := d.shutdown(); err != nil {
+				t.Fatalf("Daemon.shutdown: unexpected error %v", err)
+			}
+			if cleared != tc.wantCleared {
+				t.Errorf("Daemon.shutdown: want cleared %v, got %v", tc.wantCleared, cleared)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// observationsDir keeps the last good weather of every location in the state directory.
+	observationsDir = "observations"
+
+	onErrorFail = "fail"
+	onErrorKeep = "keep"
+)
+
+// ErrStatusKept means the status update was skipped, leaving the current status untouched.
+var ErrStatusKept = errors.New("status left untouched")
+
+// lastObservation is the last weather, the provider returned for a location.
+type lastObservation struct {
+	Location    string          `json:"location"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	Observation WeatherResponse `json:"observation"`
+}
+
+func observationFile(location string) string {
+	sum := sha256.Sum256([]byte(normalizeLocation(location)))
+	return filepath.Join(observationsDir, hex.EncodeToString(sum[:8])+".json")
+}
+
+// saveObservation keeps the weather, so it can stand in for the provider, when it fails.
+func saveObservation(cfg Config, wr WeatherResponse, now time.Time) error {
+	return writeStateFile(cfg.StateDir, observationFile(cfg.OWM.Query), lastObservation{
+		Location:    normalizeLocation(cfg.OWM.Query),
+		ReceivedAt:  now,
+		Observation: wr,
+	})
+}
+
+// degradedWeather decides what to do, when the provider failed with the error: use the last good weather,
+// if it's not older than degraded.max_age, marking it stale, or leave the status untouched, or fail.
+// Running out of the API calls budget never fails the run.
+func degradedWeather(ctx context.Context, cfg Config, providerErr error, now time.Time) (WeatherResponse, error) {
+	if cfg.Degraded.MaxAge > 0 {
+		var last lastObservation
+		err := readStateFile(cfg.StateDir, observationFile(cfg.OWM.Query), &last)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.WarnContext(ctx, "failed to read last weather", "error", err)
+		} else if err == nil {
+			age := now.Sub(last.ReceivedAt)
+			if age <= cfg.Degraded.MaxAge {
+				slog.WarnContext(ctx, "using last good weather", "location", last.Location, "received_at", last.ReceivedAt, "age", age.Round(time.Second), "error", providerErr)
+				wr := last.Observation
+				wr.Stale = true
+				return wr, nil
+			}
+			slog.InfoContext(ctx, "last good weather is too old", "location", last.Location, "received_at", last.ReceivedAt, "max_age", cfg.Degraded.MaxAge)
+		}
+	}
+
+	if cfg.Degraded.OnError == onErrorKeep || errors.Is(providerErr, ErrQuotaExhausted) {
+		slog.WarnContext(ctx, "leaving gh status untouched", "reason", providerErr)
+		return WeatherResponse{}, fmt.Errorf("%w: %v", ErrStatusKept, providerErr)
+	}
+	return WeatherResponse{}, providerErr
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDegradedWeather(t *testing.T) {
+	providerErr := errors.New("weather API responded 503 Service Unavailable")
+	now := time.Date(2020, 4, 23, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name        string
+		maxAge      time.Duration
+		onError     string
+		receivedAt  time.Time
+		providerErr error
+		wantStale   bool
+		wantErrIs   error
+	}{
+		{"stale", time.Hour, "", now.Add(-time.Hour), providerErr, true, nil},
+		{"too old", time.Hour, "", now.Add(-61 * time.Minute), providerErr, false, providerErr},
+		{"disabled", 0, "", now.Add(-time.Minute), providerErr, false, providerErr},
+		{"keep", time.Hour, onErrorKeep, now.Add(-2 * time.Hour), providerErr, false, ErrStatusKept},
+		{"quota", 0, onErrorFail, now, ErrQuotaExhausted, false, ErrStatusKept},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var cfg Config
+			cfg.StateDir = t.TempDir()
+			cfg.OWM.Query = "Berlin,DE"
+			cfg.Degraded.MaxAge = tc.maxAge
+			cfg.Degraded.OnError = tc.onError
+
+			// The observation is shared by the spellings of the location.
+			var wr WeatherResponse
+			wr.Name = "Berlin"
+			wr.Main.Temp = 9.07
+			if err := saveObservation(cfg, wr, tc.receivedAt); err != nil {
+				t.Fatalf("saveObservation: %v", err)
+			}
+			cfg.OWM.Query = "berlin, de"
+
+			got, err := degradedWeather(context.Background(), cfg, tc.providerErr, now)
+			if tc.wantErrIs != nil {
+				if !errors.Is(err, tc.wantErrIs) {
+					t.Fatalf("degradedWeather: want error %v, got %v", tc.wantErrIs, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("degradedWeather: unexpected error %v", err)
+			}
+			if got.Stale != tc.wantStale || got.Name != wr.Name {
+				t.Errorf("degradedWeather: want stale %q, got %+v", wr.Name, got)
+			}
+		})
+	}
+}
+
+func TestNewStatus_stale(t *testing.T) {
+	var cfg Config
+	cfg.Degraded.Marker = "~"
+
+	var wr WeatherResponse
+	wr.Name = "Berlin"
+	wr.Main.Temp = 9.07
+
+	if got, want := newStatus(cfg, wr, time.Now()).Message, "Berlin, +9°"; got != want {
+		t.Errorf("newStatus: want %q, got %q", want, got)
+	}
+	wr.Stale = true
+	if got, want := newStatus(cfg, wr, time.Now()).Message, "Berlin, ~+9°"; got != want {
+		t.Errorf("newStatus: want %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// maxClockSkew is the clock difference with the servers, that is worth a warning. The program corrects
+// the status' expiration time for the difference, but the other programs on the host don't.
+const maxClockSkew = time.Minute
+
+// doctorReport prints the results of the checks, counting the failures.
+type doctorReport struct {
+	w      io.Writer
+	failed int
+}
+
+func (r *doctorReport) pass(name, format string, args ...interface{}) {
+	r.print("PASS", name, format, args...)
+}
+
+func (r *doctorReport) warn(name, format string, args ...interface{}) {
+	r.print("WARN", name, format, args...)
+}
+
+func (r *doctorReport) skip(name, format string, args ...interface{}) {
+	r.print("SKIP", name, format, args...)
+}
+
+func (r *doctorReport) fail(name, format string, args ...interface{}) {
+	r.failed++
+	r.print("FAIL", name, format, args...)
+}
+
+func (r *doctorReport) print(result, name, format string, args ...interface{}) {
+	fmt.Fprintf(r.w, "[%s] %-14s %s\n", result, name, redactor.Redact(fmt.Sprintf(format, args...)))
+}
+
+func (r *doctorReport) err() error {
+	if r.failed > 0 {
+		return fmt.Errorf("doctor found %d problem(s)", r.failed)
+	}
+	return nil
+}
+
+func runDoctor(ctx context.Context, args []string) error {
+	var (
+		debug      bool
+		configPath string
+	)
+
+	flags := newFlagSet("doctor", "Check the configuration, the API credentials and the environment")
+	flags.BoolVar(&debug, "debug", false, "Enable debug logging")
+	flags.StringVar(&configPath, "configuration", "config.yaml", "Path to configuration file")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	r := &doctorReport{w: os.Stdout}
+
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		r.fail("configuration", "%v", err)
+		return r.err()
+	}
+	if err := setupLogging(os.Stderr, cfg, debug); err != nil {
+		r.fail("configuration", "%v", err)
+	} else if err := validateConfig(cfg); err != nil {
+		r.fail("configuration", "%v", err)
+	} else {
+		r.pass("configuration", "%s is valid", configPath)
+	}
+
+	checkProxy(r, cfg)
+	spent := checkOWM(ctx, r, cfg)
+	checkQuota(r, cfg, spent)
+	checkGitHub(ctx, r, cfg, debug)
+
+	return r.err()
+}
+
+// checkProxy reports the proxies, the program uses to reach the APIs.
+func checkProxy(r *doctorReport, cfg Config) {
+	apis := []struct {
+		endpoint string
+		http     HTTPConfig
+	}{
+		{cfg.OWM.Endpoint, cfg.OWM.HTTP},
+		{cfg.GitHub.Endpoint, cfg.GitHub.HTTP},
+	}
+	for _, api := range apis {
+		req, err := http.NewRequest(http.MethodGet, api.endpoint, nil)
+		if err != nil {
+			r.fail("proxy", "invalid endpoint: %v", err)
+			continue
+		}
+		proxy, err := proxyFunc(api.http)
+		if err != nil {
+			r.fail("proxy", "%v", err)
+			continue
+		}
+		proxyURL, err := proxy(req)
+		if err != nil {
+			r.fail("proxy", "invalid proxy settings: %v", err)
+		} else if proxyURL != nil {
+			r.pass("proxy", "%s via %s", req.URL.Host, proxyURL.Redacted())
+		} else {
+			r.pass("proxy", "%s direct", req.URL.Host)
+		}
+	}
+}
+
+// checkOWM requests the weather, to verify the API key, and returns the number of API calls it spent from the budget.
+func checkOWM(ctx context.Context, r *doctorReport, cfg Config) (spent int) {
+	if cfg.OWM.ApiKey == "" {
+		r.skip("openweather", "owm api key is empty")
+		return 0
+	}
+
+	owm, err := newOWMClient(cfg)
+	if err != nil {
+		r.fail("openweather", "%v", err)
+		return 0
+	}
+	if owm.quota != nil {
+		if err := owm.quota.Take(time.Now()); errors.Is(err, ErrQuotaExhausted) {
+			r.skip("openweather", "API key isn't checked: %v", err)
+			return 0
+		} else if err != nil {
+			r.warn("quota", "%v", err)
+		} else {
+			spent = 1
+		}
+	}
+	resp, err := owm.get(ctx, cfg.OWM.Query, nil)
+	if err != nil {
+		checkTLSError(r, err)
+		r.fail("openweather", "%v", err)
+		return spent
+	}
+	resp.Body.Close()
+
+	checkTLS(r, resp.Request.URL, resp.TLS)
+
+	switch code := resp.StatusCode; code {
+	case http.StatusOK:
+		r.pass("openweather", "API key is valid, got weather for %q", cfg.OWM.Query)
+	case http.StatusUnauthorized:
+		r.fail("openweather", "API key is invalid or not activated yet (HTTP %d)", code)
+	case http.StatusTooManyRequests:
+		r.fail("openweather", "API key is valid, but its calls limit is exceeded (HTTP %d)", code)
+	case http.StatusNotFound:
+		r.fail("openweather", "location %q not found (HTTP %d)", cfg.OWM.Query, code)
+	default:
+		r.fail("openweather", "unexpected response status %s", resp.Status)
+	}
+	return spent
+}
+
+// checkQuota reports the remaining API calls budget, after the doctor spent its own calls.
+func checkQuota(r *doctorReport, cfg Config, spent int) {
+	if cfg.OWM.Quota.Disabled {
+		r.skip("quota", "API calls budget is disabled")
+		return
+	}
+	q := NewQuota("owm", cfg.StateDir, cfg.OWM.Quota.PerMinute, cfg.OWM.Quota.PerDay)
+	u, err := q.Usage(time.Now())
+	if err != nil {
+		r.warn("quota", "%v", err)
+		return
+	}
+	report := r.pass
+	if u.MinuteRemaining < 1 || u.DayRemaining < 1 {
+		report = r.warn
+	}
+	report("quota", "%d of %d calls per minute, %d of %d calls per day remaining, the doctor used %d",
+		int(u.MinuteRemaining), u.PerMinute, int(u.DayRemaining), u.PerDay, spent)
+}
+
+func checkGitHub(ctx context.Context, r *doctorReport, cfg Config, debug bool) {
+	if cfg.GitHub.Token == "" && cfg.GitHub.TokenSource != tokenSourceGH {
+		r.skip("github", "github api token is empty")
+		return
+	}
+
+	gh, err := newGitHubClient(ctx, cfg, debug)
+	if err != nil {
+		r.fail("github", "%v", err)
+		return
+	}
+	ti, err := gh.TokenInfo(ctx)
+	if err != nil {
+		checkTLSError(r, err)
+		r.fail("github", "%v", err)
+		return
+	}
+
+	if u, err := url.Parse(cfg.GitHub.Endpoint); err == nil {
+		checkTLS(r, u, ti.TLS)
+	}
+
+	r.pass("github", "token is valid, authenticated as %s", ti.Login)
+
+	switch {
+	case ti.FineGrained:
+		checkPermissions(ctx, r, gh, ti)
+	case ti.HasScope("user"):
+		r.pass("github scopes", "token has \"user\" scope")
+	default:
+		r.fail("github scopes", "token doesn't have \"user\" scope, that is required to change user's status (scopes: %q)", strings.Join(ti.Scopes, ", "))
+	}
+
+	if !ti.ExpiresAt.IsZero() {
+		if left := ti.ExpiresAt.Sub(ti.LocalTime); left < 7*24*time.Hour {
+			r.warn("github expiry", "token expires in %s, at %s", left.Round(time.Minute), ti.ExpiresAt.Format(time.RFC3339))
+		} else {
+			r.pass("github expiry", "token expires at %s", ti.ExpiresAt.Format(time.RFC3339))
+		}
+	}
+
+	if ti.ServerTime.IsZero() {
+		r.skip("clock", "github response has no Date header")
+		return
+	}
+	// Date header has a second precision.
+	skew := ti.LocalTime.Sub(ti.ServerTime).Truncate(time.Second)
+	abs := skew
+	if abs < 0 {
+		abs = -abs
+	}
+	switch {
+	case abs >= maxClockSkew:
+		r.warn("clock", "local clock differs from github by %s, the program corrects for it, but consider setting up NTP", skew)
+	case abs > 5*time.Second:
+		r.warn("clock", "local clock differs from github by %s", skew)
+	default:
+		r.pass("clock", "local clock is in sync with github")
+	}
+}
+
+// checkPermissions reports what can be verified about the permissions of a fine-grained token. GitHub doesn't
+// expose them, and the token's write access to the status can't be verified without changing the status,
+// so the doctor only verifies, that the token can read it.
+func checkPermissions(ctx context.Context, r *doctorReport, gh *GitHubClient, ti TokenInfo) {
+	if _, err := gh.UserStatus(ctx); err != nil {
+		r.fail("github scopes", "fine-grained token can't read user's status: %v", err)
+		return
+	}
+	if ti.AcceptedPermissions != "" {
+		r.warn("github scopes", "fine-grained token can read user's status, its write access can't be verified (accepted permissions: %q)", ti.AcceptedPermissions)
+		return
+	}
+	r.warn("github scopes", "fine-grained token can read user's status, its write access can't be verified; make sure it can change user's status")
+}
+
+// checkTLS reports the certificate, the server was verified with.
+func checkTLS(r *doctorReport, u *url.URL, state *tls.ConnectionState) {
+	if state == nil {
+		if u.Scheme == "https" {
+			r.warn("tls", "%s: no TLS connection details", u.Host)
+		}
+		return
+	}
+	if len(state.PeerCertificates) == 0 {
+		return
+	}
+	cert := state.PeerCertificates[0]
+	r.pass("tls", "%s: trusted certificate, issued by %q, expires at %s", u.Host, cert.Issuer.CommonName, cert.NotAfter.Format(time.RFC3339))
+}
+
+// checkTLSError reports the failures to verify server's certificate, that usually mean a missing private CA.
+func checkTLSError(r *doctorReport, err error) {
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		hostnameErr      x509.HostnameError
+		invalidErr       x509.CertificateInvalidError
+	)
+	switch {
+	case errors.As(err, &unknownAuthority):
+		var issuer string
+		if unknownAuthority.Cert != nil {
+			issuer = unknownAuthority.Cert.Issuer.CommonName
+		}
+		r.fail("tls", "certificate signed by unknown authority %q; is there a TLS-intercepting proxy or a private CA, missing in http.ca_file?", issuer)
+	case errors.As(err, &hostnameErr):
+		r.fail("tls", "certificate isn't valid for %s", hostnameErr.Host)
+	case errors.As(err, &invalidErr):
+		r.fail("tls", "invalid certificate: %v", invalidErr)
+	}
+}
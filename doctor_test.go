@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckOWM(t *testing.T) {
+	cases := []struct {
+		code int
+		want string
+	}{
+		{http.StatusOK, "[PASS] openweather    API key is valid"},
+		{http.StatusUnauthorized, "[FAIL] openweather    API key is invalid"},
+		{http.StatusTooManyRequests, "[FAIL] openweather    API key is valid, but its calls limit is exceeded"},
+	}
+
+	for _, tc := range cases {
+		t.Run(http.StatusText(tc.code), func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.code)
+			}))
+			defer ts.Close()
+
+			var cfg Config
+			cfg.OWM.Endpoint = ts.URL + "/weather?appid={api-key}"
+			cfg.OWM.ApiKey = "key"
+			cfg.OWM.Query = "Berlin"
+			cfg.OWM.Quota.Disabled = true
+
+			var buf bytes.Buffer
+			checkOWM(context.Background(), &doctorReport{w: &buf}, cfg)
+			if got := buf.String(); !strings.HasPrefix(got, tc.want) {
+				t.Errorf("checkOWM: want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestCheckOWM_quota(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer ts.Close()
+
+	var cfg Config
+	cfg.StateDir = t.TempDir()
+	cfg.OWM.Endpoint = ts.URL + "/weather?appid={api-key}"
+	cfg.OWM.ApiKey = "key"
+	cfg.OWM.Query = "Berlin"
+	cfg.OWM.Quota.PerMinute = 1
+	cfg.OWM.Quota.PerDay = 1000
+
+	cases := []struct {
+		spent    int
+		want     string
+		requests int
+	}{
+		{1, "[PASS] openweather    API key is valid", 1},
+		{0, "[SKIP] openweather    API key isn't checked: API calls budget exhausted", 1},
+	}
+
+	for n, tc := range cases {
+		t.Run(fmt.Sprintf("case=%d", n), func(t *testing.T) {
+			var buf bytes.Buffer
+			spent := checkOWM(context.Background(), &doctorReport{w: &buf}, cfg)
+			if got := buf.String(); !strings.HasPrefix(got, tc.want) {
+				t.Errorf("checkOWM: want %q, got %q", tc.want, got)
+			}
+			if spent != tc.spent {
+				t.Errorf("checkOWM: want %d spent calls, got %d", tc.spent, spent)
+			}
+			if requests != tc.requests {
+				t.Errorf("checkOWM: want %d requests, got %d", tc.requests, requests)
+			}
+		})
+	}
+}
+
+func TestCheckGitHub(t *testing.T) {
+	cases := []struct {
+		name   string
+		token  string
+		scopes string
+		skew   time.Duration
+		want   []string
+	}{
+		{
+			"valid",
+			"token",
+			"repo, user",
+			0,
+			[]string{
+				"[PASS] github         token is valid, authenticated as octocat",
+				"[PASS] github scopes  token has \"user\" scope",
+				"[PASS] clock          local clock is in sync with github",
+			},
+		},
+		{
+			"no user scope",
+			"token",
+			"repo",
+			0,
+			[]string{
+				"[PASS] github         token is valid, authenticated as octocat",
+				"[FAIL] github scopes  token doesn't have \"user\" scope, that is required to change user's status (scopes: \"repo\")",
+				"[PASS] clock          local clock is in sync with github",
+			},
+		},
+		{
+			"clock skew",
+			"token",
+			"user",
+			-5 * time.Minute,
+			[]string{
+				"[PASS] github         token is valid, authenticated as octocat",
+				"[PASS] github scopes  token has \"user\" scope",
+				"[WARN] clock          local clock differs from github by 5m0s, the program corrects for it, but consider setting up NTP",
+			},
+		},
+		{
+			"fine-grained",
+			"github_pat_token",
+			"",
+			0,
+			[]string{
+				"[PASS] github         token is valid, authenticated as octocat",
+				"[WARN] github scopes  fine-grained token can read user's status, its write access can't be verified; make sure it can change user's status",
+				"[PASS] clock          local clock is in sync with github",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-OAuth-Scopes", tc.scopes)
+				w.Header().Set("Date", time.Now().Add(tc.skew).UTC().Format(http.TimeFormat))
+				w.Write([]byte(`{"data":{"viewer":{"login":"octocat","status":null}}}`))
+			}))
+			defer ts.Close()
+
+			var cfg Config
+			cfg.GitHub.Endpoint = ts.URL
+			cfg.GitHub.Token = tc.token
+
+			var buf bytes.Buffer
+			r := &doctorReport{w: &buf}
+			checkGitHub(context.Background(), r, cfg, false)
+			if got := strings.TrimSpace(buf.String()); got != strings.Join(tc.want, "\n") {
+				t.Errorf("checkGitHub: want\n%s\ngot\n%s", strings.Join(tc.want, "\n"), got)
+			}
+		})
+	}
+}
+
+func TestCheckGitHub_unknownAuthority(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	var cfg Config
+	cfg.GitHub.Endpoint = ts.URL
+	cfg.GitHub.Token = "token"
+
+	var buf bytes.Buffer
+	r := &doctorReport{w: &buf}
+	checkGitHub(context.Background(), r, cfg, false)
+	if got := buf.String(); !strings.HasPrefix(got, "[FAIL] tls            certificate signed by unknown authority") {
+		t.Errorf("checkGitHub: want TLS failure, got %q", got)
+	}
+	if r.err() == nil {
+		t.Errorf("doctorReport.err: want error, got nil")
+	}
+}
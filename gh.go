@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"gopkg.in/yaml.v2"
+)
+
+// tokenSourceGH is the token source, that reuses the credentials of GitHub CLI.
+const tokenSourceGH = "gh"
+
+// ghConfigDir returns the configuration directory of GitHub CLI, the same way gh finds it.
+func ghConfigDir() (string, error) {
+	if dir := os.Getenv("GH_CONFIG_DIR"); dir != "" {
+		return dir, nil
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gh"), nil
+	}
+	if dir := os.Getenv("AppData"); runtime.GOOS == "windows" && dir != "" {
+		return filepath.Join(dir, "GitHub CLI"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "gh"), nil
+}
+
+// ghHostsToken reads the OAuth token of the host from gh's hosts.yml.
+func ghHostsToken(path, host string) (string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%s not found, run: gh auth login --hostname %s", path, host)
+	} else if err != nil {
+		return "", fmt.Errorf("error reading gh hosts file: %v", err)
+	}
+
+	var hosts map[string]struct {
+		User       string `yaml:"user"`
+		OAuthToken string `yaml:"oauth_token"`
+	}
+	if err := yaml.Unmarshal(data, &hosts); err != nil {
+		return "", fmt.Errorf("error parsing gh hosts file %q: %v", path, err)
+	}
+
+	h, ok := hosts[host]
+	if !ok {
+		return "", fmt.Errorf("gh isn't logged in to %s, run: gh auth login --hostname %s", host, host)
+	}
+	if h.OAuthToken == "" {
+		// Since version 2.40, gh keeps the token in the system's keyring by default.
+		return "", fmt.Errorf("gh stores the token for %s outside of %s, run: gh auth login --hostname %s --insecure-storage", host, path, host)
+	}
+	return h.OAuthToken, nil
+}
+
+// loadGHToken sets the configuration's token to the one of GitHub CLI, for the configured GitHub host.
+func loadGHToken(cfg *Config) error {
+	host, err := githubHost(cfg.GitHub.Endpoint)
+	if err != nil {
+		return err
+	}
+	dir, err := ghConfigDir()
+	if err != nil {
+		return fmt.Errorf("error finding gh configuration directory: %v", err)
+	}
+	token, err := ghHostsToken(filepath.Join(dir, "hosts.yml"), host)
+	if err != nil {
+		return err
+	}
+	cfg.GitHub.Token = token
+	return nil
+}
+
+// verifyGHToken checks that the token of GitHub CLI can change user's status. The scopes, gh requests by default,
+// don't include "user".
+func verifyGHToken(ctx context.Context, cfg Config, gh *GitHubClient) error {
+	ti, err := gh.TokenInfo(ctx)
+	if err != nil {
+		return err
+	}
+	if !ti.HasScope("user") {
+		host, _ := githubHost(cfg.GitHub.Endpoint)
+		return fmt.Errorf("gh token doesn't have \"user\" scope, that is required to change user's status, run: gh auth refresh --hostname %s --scopes user", host)
+	}
+	return nil
+}
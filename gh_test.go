@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadGHToken(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GH_CONFIG_DIR", dir)
+
+	hosts := `github.com:
+    user: octocat
+    oauth_token: gho_public
+    git_protocol: https
+github.example.com:
+    user: octocat
+    oauth_token: gho_enterprise
+keyring.example.com:
+    user: octocat
+`
+	if err := os.WriteFile(filepath.Join(dir, "hosts.yml"), []byte(hosts), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		endpoint string
+		want     string
+		wantErr  bool
+	}{
+		{defaultGitHubAPIEndpoint, "gho_public", false},
+		{"https://github.example.com/api/graphql", "gho_enterprise", false},
+		{"https://keyring.example.com/api/graphql", "", true},
+		{"https://unknown.example.com/api/graphql", "", true},
+	}
+
+	for n, tc := range cases {
+		t.Run(fmt.Sprintf("case=%d", n), func(t *testing.T) {
+			var cfg Config
+			cfg.GitHub.Endpoint = tc.endpoint
+			err := loadGHToken(&cfg)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("loadGHToken: want error %v, got %v", tc.wantErr, err)
+			}
+			if cfg.GitHub.Token != tc.want {
+				t.Errorf("loadGHToken: want %q, got %q", tc.want, cfg.GitHub.Token)
+			}
+		})
+	}
+}
+
+func TestVerifyGHToken(t *testing.T) {
+	cases := []struct {
+		scopes  string
+		wantErr bool
+	}{
+		{"gist, read:org, repo, user", false},
+		{"gist, read:org, repo, workflow", true},
+	}
+
+	for n, tc := range cases {
+		t.Run(fmt.Sprintf("case=%d", n), func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-OAuth-Scopes", tc.scopes)
+				w.Write([]byte(`{"data":{"viewer":{"login":"octocat"}}}`))
+			}))
+			defer ts.Close()
+
+			var cfg Config
+			cfg.GitHub.Endpoint = ts.URL
+			err := verifyGHToken(context.Background(), cfg, NewGitHubClient(ts.URL, "gho_token", nil))
+			if (err != nil) != tc.wantErr {
+				t.Errorf("verifyGHToken: want error %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateGitHubConfig_tokenSource(t *testing.T) {
+	cases := []struct {
+		token       string
+		tokenSource string
+		wantErr     bool
+	}{
+		{"ghp_token", "", false},
+		{"", "", true},
+		{"", tokenSourceGH, false},
+		{"ghp_token", tokenSourceGH, true},
+		{"", "keyring", true},
+	}
+
+	for n, tc := range cases {
+		t.Run(fmt.Sprintf("case=%d", n), func(t *testing.T) {
+			var cfg Config
+			cfg.GitHub.Endpoint = defaultGitHubAPIEndpoint
+			cfg.GitHub.Token = tc.token
+			cfg.GitHub.TokenSource = tc.tokenSource
+			if err := validateGitHubConfig(cfg); (err != nil) != tc.wantErr {
+				t.Errorf("validateGitHubConfig: want error %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_tokenSourceGH(t *testing.T) {
+	// gh isn't logged in, but loading the configuration doesn't need its token.
+	t.Setenv("GH_CONFIG_DIR", t.TempDir())
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte("github:\n  token_source: gh\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatalf("loadConfig: unexpected error %v", err)
+	}
+	if cfg.GitHub.Token != "" {
+		t.Errorf("loadConfig: want empty token, got %q", cfg.GitHub.Token)
+	}
+	if _, err := newGitHubClient(context.Background(), cfg, false); err == nil {
+		t.Error("newGitHubClient: want error without gh's token, got nil")
+	}
+}
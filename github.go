@@ -0,0 +1,575 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/machinebox/graphql"
+)
+
+type GitHubClient struct {
+	apiURL  string
+	token   string
+	client  *graphql.Client
+	opts    []graphql.ClientOption
+	backoff backoff
+	// debug logs the requests and the responses of the graphql client, with the context of the request.
+	debug bool
+}
+
+// apiResponse is the metadata of a GitHub API response.
+type apiResponse struct {
+	StatusCode int
+	Header     http.Header
+	TLS        *tls.ConnectionState
+	ReceivedAt time.Time
+	// ErrorType and Message describe the first error of the response, if any.
+	ErrorType string
+	Message   string
+	// RateLimitRemaining is the number of the remaining points of the rate limit, or -1 if unknown.
+	RateLimitRemaining int
+	RateLimitReset     time.Time
+}
+
+const (
+	// maxGitHubResponseSize limits the response body, that is inspected for the errors.
+	maxGitHubResponseSize = 1 << 20
+	// lowGitHubRateLimit is the remaining rate limit, below which the program logs warnings.
+	lowGitHubRateLimit = 100
+)
+
+// NewGitHubClient creates the client of GitHub GraphQL API, sending the requests with the HTTP client.
+// Nil HTTP client is the same as the default client.
+func NewGitHubClient(apiURL, token string, httpClient *http.Client, opts ...graphql.ClientOption) *GitHubClient {
+	c := &GitHubClient{
+		apiURL:  apiURL,
+		token:   token,
+		backoff: defaultGitHubBackoff,
+	}
+	httpClient = observeClient(httpClient, c.observeResponse)
+	// Options, passed by the caller, go last to allow overriding the HTTP client.
+	c.opts = append([]graphql.ClientOption{graphql.WithHTTPClient(httpClient)}, opts...)
+	c.client = graphql.NewClient(apiURL, c.opts...)
+	return c
+}
+
+type ChangeUserStatusInput struct {
+	ClientMutationID    string    `json:"clientMutationId,omitempty"`
+	Emoji               string    `json:"emoji,omitempty"`
+	ExpiresAt           time.Time `json:"expiresAt,omitempty"`
+	LimitedAvailability bool      `json:"limitedAvailability,omitempty"`
+	Message             string    `json:"message,omitempty"`
+	OrganizationID      string    `json:"organizationId,omitempty"`
+}
+
+type ChangeUserStatusResponse struct {
+	ID        string    `json:"id"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+const mutationChangeUserStatus = `
+	mutation ChangeUserStatus($status: ChangeUserStatusInput!) {
+	  changeUserStatus(input: $status) {
+		status {
+		  id
+		  updatedAt
+		  expiresAt
+		}
+	  }
+	}
+`
+
+func (c *GitHubClient) ChangeUserStatus(ctx context.Context, input ChangeUserStatusInput) (ChangeUserStatusResponse, error) {
+	req := graphql.NewRequest(mutationChangeUserStatus)
+	req.Var("status", input)
+
+	resp := struct {
+		ChangeUserStatus struct {
+			Status ChangeUserStatusResponse `json:"status"`
+		} `json:"changeUserStatus"`
+	}{}
+	ar, err := c.runWithResponse(ctx, "ChangeUserStatus", req, &resp)
+	if err != nil {
+		return ChangeUserStatusResponse{}, fmt.Errorf("github API request failed: %w", err)
+	}
+
+	// The status is updated by this request, if its updatedAt isn't earlier than the time GitHub handled the request.
+	// The local clock can't be trusted for the check, but the Date of the response can.
+	handledAt, err := http.ParseTime(ar.Header.Get("Date"))
+	if err != nil {
+		handledAt = serverClock.Now()
+	}
+	status := resp.ChangeUserStatus.Status
+	if status.UpdatedAt.Before(handledAt.Add(-time.Minute)) {
+		return ChangeUserStatusResponse{}, fmt.Errorf("status not updated, github API response: %v", resp)
+	}
+
+	return status, nil
+}
+
+type UserStatus struct {
+	Emoji     string    `json:"emoji"`
+	Message   string    `json:"message"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+const queryViewerStatus = `
+	query ViewerStatus {
+	  rateLimit {
+		remaining
+		resetAt
+	  }
+	  viewer {
+		status {
+		  emoji
+		  message
+		  updatedAt
+		  expiresAt
+		}
+	  }
+	}
+`
+
+// UserStatus returns the current status of the viewer, or nil if the status is not set.
+func (c *GitHubClient) UserStatus(ctx context.Context) (*UserStatus, error) {
+	req := graphql.NewRequest(queryViewerStatus)
+
+	resp := struct {
+		Viewer struct {
+			Status *UserStatus `json:"status"`
+		} `json:"viewer"`
+	}{}
+	if err := c.run(ctx, "ViewerStatus", req, &resp); err != nil {
+		return nil, fmt.Errorf("github API request failed: %w", err)
+	}
+
+	return resp.Viewer.Status, nil
+}
+
+const mutationClearUserStatus = `
+	mutation ClearUserStatus($clientMutationId: String) {
+	  changeUserStatus(input: {clientMutationId: $clientMutationId}) {
+		status {
+		  id
+		}
+	  }
+	}
+`
+
+// ClearUserStatus removes the status of the viewer.
+func (c *GitHubClient) ClearUserStatus(ctx context.Context, clientMutationID string) error {
+	req := graphql.NewRequest(mutationClearUserStatus)
+	req.Var("clientMutationId", clientMutationID)
+
+	resp := struct {
+		ChangeUserStatus struct {
+			Status *ChangeUserStatusResponse `json:"status"`
+		} `json:"changeUserStatus"`
+	}{}
+	if err := c.run(ctx, "ClearUserStatus", req, &resp); err != nil {
+		return fmt.Errorf("github API request failed: %w", err)
+	}
+
+	if status := resp.ChangeUserStatus.Status; status != nil {
+		return fmt.Errorf("status not cleared, github API response: %+v", *status)
+	}
+
+	return nil
+}
+
+const queryOrganizationID = `
+	query OrganizationID($login: String!) {
+	  rateLimit {
+		remaining
+		resetAt
+	  }
+	  organization(login: $login) {
+		id
+	  }
+	}
+`
+
+// OrganizationID returns the node ID of the organization with the login.
+func (c *GitHubClient) OrganizationID(ctx context.Context, login string) (string, error) {
+	req := graphql.NewRequest(queryOrganizationID)
+	req.Var("login", login)
+
+	resp := struct {
+		Organization *struct {
+			ID string `json:"id"`
+		} `json:"organization"`
+	}{}
+	if err := c.run(ctx, "OrganizationID", req, &resp); err != nil {
+		return "", fmt.Errorf("github API request failed: %w", err)
+	}
+
+	if resp.Organization == nil {
+		return "", fmt.Errorf("organization %q not found", login)
+	}
+	return resp.Organization.ID, nil
+}
+
+// TokenInfo describes the API token, as seen by GitHub.
+type TokenInfo struct {
+	Login string
+	// Scopes are the OAuth scopes of a classic token.
+	Scopes []string
+	// FineGrained is true for fine-grained personal access tokens, that have permissions instead of scopes.
+	FineGrained bool
+	// AcceptedPermissions are the fine-grained permissions, the request required, if GitHub reported them.
+	AcceptedPermissions string
+	// ExpiresAt is the token's expiration time, if the token expires.
+	ExpiresAt time.Time
+	// ServerTime is GitHub's time, from the response's Date header, received at local time LocalTime.
+	ServerTime time.Time
+	LocalTime  time.Time
+	TLS        *tls.ConnectionState
+}
+
+// HasScope reports whether the token was granted the OAuth scope.
+func (ti TokenInfo) HasScope(scope string) bool {
+	for _, s := range ti.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+const queryViewerLogin = `
+	query ViewerLogin {
+	  rateLimit {
+		remaining
+		resetAt
+	  }
+	  viewer {
+		login
+	  }
+	}
+`
+
+// TokenInfo requests the viewer's login and describes the client's API token, from the headers of the response.
+func (c *GitHubClient) TokenInfo(ctx context.Context) (TokenInfo, error) {
+	req := graphql.NewRequest(queryViewerLogin)
+
+	resp := struct {
+		Viewer struct {
+			Login string `json:"login"`
+		} `json:"viewer"`
+	}{}
+	last, err := c.runWithResponse(ctx, "ViewerLogin", req, &resp)
+
+	if last.StatusCode == http.StatusUnauthorized {
+		return TokenInfo{}, fmt.Errorf("github API rejected the token: %s", http.StatusText(last.StatusCode))
+	}
+	if err != nil {
+		return TokenInfo{}, fmt.Errorf("github API request failed: %w", err)
+	}
+
+	ti := TokenInfo{
+		Login:               resp.Viewer.Login,
+		FineGrained:         strings.HasPrefix(c.token, "github_pat_"),
+		AcceptedPermissions: last.Header.Get("X-Accepted-GitHub-Permissions"),
+		LocalTime:           last.ReceivedAt,
+		TLS:                 last.TLS,
+	}
+	for _, s := range strings.Split(last.Header.Get("X-OAuth-Scopes"), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			ti.Scopes = append(ti.Scopes, s)
+		}
+	}
+	if exp := last.Header.Get("GitHub-Authentication-Token-Expiration"); exp != "" {
+		ti.ExpiresAt, _ = time.Parse("2006-01-02 15:04:05 MST", exp)
+	}
+	ti.ServerTime, _ = http.ParseTime(last.Header.Get("Date"))
+
+	return ti, nil
+}
+
+type apiResponseKey struct{}
+
+// defaultGitHubBackoff retries the failed requests for up to about 10 seconds, unless GitHub asks to wait longer.
+var defaultGitHubBackoff = backoff{Attempts: 4, Base: time.Second, Max: time.Minute}
+
+// retrySafeOperations are the operations, that are safe to repeat after a failure, that could have happened after
+// GitHub had executed them: the queries don't change anything, and the mutations set user's status to the same
+// value, no matter how many times they are executed. Other operations are only retried, when GitHub rejected
+// them before the execution, i.e. when rate limited.
+var retrySafeOperations = map[string]bool{
+	"ViewerStatus":     true,
+	"ViewerLogin":      true,
+	"OrganizationID":   true,
+	"ChangeUserStatus": true,
+	"ClearUserStatus":  true,
+}
+
+// GitHubError is a failed GitHub API request, that GitHub responded to with an HTTP error, or a GraphQL error
+// of a known type.
+type GitHubError struct {
+	StatusCode int
+	// Type is the type of the GraphQL error, e.g. "RATE_LIMITED".
+	Type    string
+	Message string
+	// RetryAfter is how long GitHub asked to wait before retrying the request, if it did.
+	RetryAfter  time.Duration
+	rateLimited bool
+}
+
+func (e *GitHubError) Error() string {
+	s := fmt.Sprintf("github API responded %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Type != "" {
+		s += " " + e.Type
+	}
+	if e.Message != "" {
+		s += ": " + e.Message
+	}
+	return s
+}
+
+func (e *GitHubError) Is(target error) bool {
+	return target == ErrRateLimited && e.rateLimited
+}
+
+// Temporary reports whether the request may succeed, if retried.
+func (e *GitHubError) Temporary() bool {
+	switch e.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return e.rateLimited
+}
+
+func (c *GitHubClient) run(ctx context.Context, operation string, req *graphql.Request, resp interface{}) error {
+	_, err := c.runWithResponse(ctx, operation, req, resp)
+	return err
+}
+
+// runWithResponse runs the request, retrying the temporary failures, and returns the metadata of its last
+// HTTP response.
+func (c *GitHubClient) runWithResponse(ctx context.Context, operation string, req *graphql.Request, resp interface{}) (apiResponse, error) {
+	ctx, span := startSpan(ctx, "GitHubClient."+operation)
+	span.SetAttr("graphql.operation.name", operation)
+	defer span.End()
+
+	if c.token != "" {
+		req.Header.Set("Authorization", "bearer "+c.token)
+	}
+
+	var (
+		ar  apiResponse
+		err error
+	)
+	for attempt := 0; ; attempt++ {
+		ar, err = c.runOnce(ctx, operation, req, resp)
+		if err == nil {
+			break
+		}
+		wait, ok := c.retryDelay(ctx, operation, err, attempt)
+		if !ok {
+			break
+		}
+		slog.WarnContext(ctx, "retrying github request", "operation", operation, "attempt", attempt+1, "wait", wait, "error", err)
+		metrics.GitHubRetries.Add(1, operation)
+		if serr := sleep(ctx, wait); serr != nil {
+			slog.WarnContext(ctx, "giving up github request", "operation", operation, "reason", serr)
+			break
+		}
+	}
+	span.SetError(err)
+	return ar, err
+}
+
+func (c *GitHubClient) runOnce(ctx context.Context, operation string, req *graphql.Request, resp interface{}) (apiResponse, error) {
+	var ar apiResponse
+	ctx = context.WithValue(ctx, apiResponseKey{}, &ar)
+
+	defer metrics.GitHubDuration.ObserveSince(time.Now(), operation)
+
+	client := c.client
+	if c.debug {
+		// The graphql client's log has no context, so the client, that logs with the request's context, e.g.
+		// its run ID, is made for every request.
+		client = graphql.NewClient(c.apiURL, c.opts...)
+		client.Log = debugLog(ctx)
+	}
+	err := client.Run(ctx, req, resp)
+	if ar.StatusCode == 0 {
+		// The request failed before GitHub responded.
+		return ar, err
+	}
+	if gerr := newGitHubError(ar); gerr != nil {
+		return ar, gerr
+	}
+	return ar, err
+}
+
+// newGitHubError classifies the failed response, returning nil for the successful responses and the GraphQL errors
+// of the query, that the graphql client reports by itself.
+func newGitHubError(ar apiResponse) *GitHubError {
+	e := &GitHubError{
+		StatusCode: ar.StatusCode,
+		Type:       ar.ErrorType,
+		Message:    ar.Message,
+	}
+
+	switch {
+	case ar.ErrorType == "RATE_LIMITED":
+		e.rateLimited = true
+	case ar.StatusCode == http.StatusForbidden || ar.StatusCode == http.StatusTooManyRequests:
+		if ar.Header.Get("Retry-After") != "" || ar.Header.Get("X-RateLimit-Remaining") == "0" ||
+			strings.Contains(strings.ToLower(ar.Message), "rate limit") {
+			e.rateLimited = true
+		}
+	case ar.StatusCode == http.StatusOK:
+		return nil
+	}
+	if e.Message == "" && ar.StatusCode != http.StatusOK {
+		e.Message = http.StatusText(ar.StatusCode)
+	}
+
+	if !e.rateLimited {
+		return e
+	}
+	if secs, err := strconv.Atoi(ar.Header.Get("Retry-After")); err == nil {
+		e.RetryAfter = time.Duration(secs) * time.Second
+	} else if !ar.RateLimitReset.IsZero() && ar.RateLimitRemaining == 0 {
+		e.RetryAfter = ar.RateLimitReset.Sub(serverClock.Now())
+	} else {
+		// Secondary rate limits without Retry-After, see
+		// https://docs.github.com/en/graphql/overview/rate-limits-and-node-limits-for-the-graphql-api#exceeding-the-rate-limit
+		e.RetryAfter = time.Minute
+	}
+	return e
+}
+
+// retryDelay decides whether to retry the failed attempt, and how long to wait before the retry.
+func (c *GitHubClient) retryDelay(ctx context.Context, operation string, err error, attempt int) (time.Duration, bool) {
+	if attempt+1 >= c.backoff.Attempts || ctx.Err() != nil {
+		return 0, false
+	}
+
+	var gerr *GitHubError
+	if errors.As(err, &gerr) {
+		switch {
+		case gerr.rateLimited && gerr.RetryAfter > c.backoff.Max:
+			return 0, false
+		case gerr.rateLimited && gerr.RetryAfter > 0:
+			return gerr.RetryAfter, true
+		case gerr.rateLimited, gerr.Temporary() && retrySafeOperations[operation]:
+			return c.backoff.delay(attempt), true
+		}
+		return 0, false
+	}
+
+	// The request may have reached GitHub before the network failed.
+	if retryableNetError(err) && retrySafeOperations[operation] {
+		return c.backoff.delay(attempt), true
+	}
+	return 0, false
+}
+
+// githubResponseBody is the part of a GraphQL response, that describes the errors and the rate limit.
+type githubResponseBody struct {
+	Data struct {
+		RateLimit *struct {
+			Remaining int       `json:"remaining"`
+			ResetAt   time.Time `json:"resetAt"`
+		} `json:"rateLimit"`
+	} `json:"data"`
+	Errors []struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"errors"`
+	// Message is the error message of non-GraphQL responses, e.g. of secondary rate limits.
+	Message string `json:"message"`
+}
+
+// observeResponse inspects the headers and the body of every GitHub API response.
+func (c *GitHubClient) observeResponse(resp *http.Response) {
+	receivedAt := time.Now()
+	serverClock.Observe(resp.Header, receivedAt)
+
+	ar := apiResponse{
+		StatusCode:         resp.StatusCode,
+		Header:             resp.Header,
+		TLS:                resp.TLS,
+		ReceivedAt:         receivedAt,
+		RateLimitRemaining: -1,
+	}
+	if remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining")); err == nil {
+		ar.RateLimitRemaining = remaining
+	}
+	if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		ar.RateLimitReset = time.Unix(reset, 0)
+	}
+
+	// The graphql client doesn't expose the errors' types, so the body is peeked at, before the client reads it.
+	// The larger responses are passed on whole, but not inspected.
+	if body, err := io.ReadAll(io.LimitReader(resp.Body, maxGitHubResponseSize)); err == nil {
+		resp.Body = peekedBody{
+			Reader: io.MultiReader(bytes.NewReader(body), resp.Body),
+			Closer: resp.Body,
+		}
+
+		var rb githubResponseBody
+		if json.Unmarshal(body, &rb) == nil {
+			ar.Message = rb.Message
+			if len(rb.Errors) > 0 {
+				ar.ErrorType, ar.Message = rb.Errors[0].Type, rb.Errors[0].Message
+			}
+			if rl := rb.Data.RateLimit; rl != nil {
+				ar.RateLimitRemaining, ar.RateLimitReset = rl.Remaining, rl.ResetAt
+			}
+		}
+	}
+
+	ctx := resp.Request.Context()
+	if p, ok := ctx.Value(apiResponseKey{}).(*apiResponse); ok {
+		*p = ar
+	}
+
+	if ar.RateLimitRemaining >= 0 {
+		metrics.GitHubRateLimit.Set(float64(ar.RateLimitRemaining))
+		if !ar.RateLimitReset.IsZero() {
+			metrics.GitHubRateLimitReset.Set(float64(ar.RateLimitReset.Unix()))
+		}
+		level := slog.LevelDebug
+		if ar.RateLimitRemaining < lowGitHubRateLimit {
+			level = slog.LevelWarn
+		}
+		slog.Log(ctx, level, "github rate limit", "remaining", ar.RateLimitRemaining, "reset_at", ar.RateLimitReset)
+	}
+}
+
+// peekedBody is the response body, which beginning was read, followed by the unread rest.
+type peekedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// responseObserver is an http.RoundTripper, that passes every response to the observe function,
+// before returning it to the caller.
+type responseObserver struct {
+	next    http.RoundTripper
+	observe func(*http.Response)
+}
+
+func (t *responseObserver) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err == nil {
+		t.observe(resp)
+	}
+	return resp, err
+}
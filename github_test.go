@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGitHubClient_retry(t *testing.T) {
+	updated := `{"data":{"changeUserStatus":{"status":{"id":"1","updatedAt":"` + time.Now().UTC().Format(time.RFC3339) + `"}}}}`
+
+	cases := []struct {
+		name         string
+		responses    []func(w http.ResponseWriter)
+		timeout      time.Duration
+		wantRequests int
+		wantErr      bool
+		wantErrIs    error
+	}{
+		{
+			name: "bad gateway",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.WriteHeader(http.StatusBadGateway)
+					w.Write([]byte("<html>Bad Gateway</html>"))
+				},
+				func(w http.ResponseWriter) { w.Write([]byte(updated)) },
+			},
+			wantRequests: 2,
+		},
+		{
+			name: "secondary rate limit",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(http.StatusForbidden)
+					w.Write([]byte(`{"message":"You have exceeded a secondary rate limit."}`))
+				},
+				func(w http.ResponseWriter) { w.Write([]byte(updated)) },
+			},
+			wantRequests: 2,
+		},
+		{
+			name: "graphql rate limited",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set("X-RateLimit-Remaining", "0")
+					w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Unix(), 10))
+					w.Write([]byte(`{"errors":[{"type":"RATE_LIMITED","message":"API rate limit exceeded for user ID 1."}]}`))
+				},
+			},
+			wantRequests: 3,
+			wantErr:      true,
+			wantErrIs:    ErrRateLimited,
+		},
+		{
+			name: "retry past deadline",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set("Retry-After", "30")
+					w.WriteHeader(http.StatusForbidden)
+					w.Write([]byte(`{"message":"You have exceeded a secondary rate limit."}`))
+				},
+			},
+			timeout:      time.Second,
+			wantRequests: 1,
+			wantErr:      true,
+			wantErrIs:    ErrRateLimited,
+		},
+		{
+			name: "bad credentials",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.WriteHeader(http.StatusUnauthorized)
+					w.Write([]byte(`{"message":"Bad credentials"}`))
+				},
+			},
+			wantRequests: 1,
+			wantErr:      true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests int
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := requests
+				if n >= len(tc.responses) {
+					n = len(tc.responses) - 1
+				}
+				requests++
+				tc.responses[n](w)
+			}))
+			defer ts.Close()
+
+			gh := NewGitHubClient(ts.URL, "token", nil)
+			gh.backoff = backoff{Attempts: 3, Base: time.Millisecond, Max: time.Minute}
+
+			ctx := context.Background()
+			if tc.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.timeout)
+				defer cancel()
+			}
+
+			_, err := gh.ChangeUserStatus(ctx, ChangeUserStatusInput{Message: "Berlin, +9°"})
+			if (err != nil) != tc.wantErr {
+				t.Errorf("GitHubClient.ChangeUserStatus: want error %v, got %v", tc.wantErr, err)
+			}
+			if tc.wantErrIs != nil && !errors.Is(err, tc.wantErrIs) {
+				t.Errorf("GitHubClient.ChangeUserStatus: want error %v, got %v", tc.wantErrIs, err)
+			}
+			if requests != tc.wantRequests {
+				t.Errorf("GitHubClient.ChangeUserStatus: want %d requests, got %d", tc.wantRequests, requests)
+			}
+		})
+	}
+}
+
+func TestGitHubClient_rateLimit(t *testing.T) {
+	resetAt := time.Date(2020, 4, 23, 13, 0, 0, 0, time.UTC)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		w.Write([]byte(`{"data":{"rateLimit":{"remaining":4990,"resetAt":"` + resetAt.Format(time.RFC3339) + `"},"viewer":{"status":null}}}`))
+	}))
+	defer ts.Close()
+
+	gh := NewGitHubClient(ts.URL, "token", nil)
+	if _, err := gh.UserStatus(context.Background()); err != nil {
+		t.Fatalf("GitHubClient.UserStatus: unexpected error %v", err)
+	}
+
+	// The rate limit of the GraphQL response is more precise than the headers.
+	if got := metrics.GitHubRateLimit.values[""]; got != 4990 {
+		t.Errorf("GitHubRateLimit: want 4990, got %v", got)
+	}
+	if got := metrics.GitHubRateLimitReset.values[""]; got != float64(resetAt.Unix()) {
+		t.Errorf("GitHubRateLimitReset: want %v, got %v", resetAt.Unix(), got)
+	}
+}
+
+func TestGitHubClient_largeResponse(t *testing.T) {
+	// The response is larger than the part, inspected for the errors, and still decoded whole.
+	message := strings.Repeat("a", maxGitHubResponseSize)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{"viewer":{"status":{"emoji":":sunny:","message":"` + message + `"}}}}`))
+	}))
+	defer ts.Close()
+
+	gh := NewGitHubClient(ts.URL, "token", nil)
+	status, err := gh.UserStatus(context.Background())
+	if err != nil {
+		t.Fatalf("GitHubClient.UserStatus: unexpected error %v", err)
+	}
+	if status == nil || status.Emoji != ":sunny:" || len(status.Message) != len(message) {
+		t.Errorf("GitHubClient.UserStatus: want the whole status, got %+v", status)
+	}
+}
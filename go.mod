@@ -1,6 +1,6 @@
 module github-weather
 
-go 1.14
+go 1.21
 
 require (
 	github.com/machinebox/graphql v0.2.2
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Heartbeat pings a dead man's switch service, compatible with healthchecks.io: the run reports its start
+// to "<url>/start", its success to "<url>" and its failure to "<url>/fail".
+type Heartbeat struct {
+	url    string
+	method string
+	client *http.Client
+}
+
+// defaultHeartbeatTimeout limits the time of a ping, when not configured.
+const defaultHeartbeatTimeout = 10 * time.Second
+
+// NewHeartbeat creates the heartbeat, pinging with the HTTP client. Nil HTTP client is the default client,
+// limited with the default timeout.
+func NewHeartbeat(url, method string, httpClient *http.Client) *Heartbeat {
+	if method == "" {
+		method = http.MethodPost
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: defaultHeartbeatTimeout}
+	}
+	return &Heartbeat{
+		url:    strings.TrimSuffix(url, "/"),
+		method: strings.ToUpper(method),
+		client: httpClient,
+	}
+}
+
+func (h *Heartbeat) Start(ctx context.Context) {
+	h.ping(ctx, "/start", "")
+}
+
+// Done reports the run's outcome.
+func (h *Heartbeat) Done(ctx context.Context, err error) {
+	if err != nil {
+		h.ping(ctx, "/fail", redactor.Redact(err.Error()))
+		return
+	}
+	h.ping(ctx, "", "")
+}
+
+// ping sends the heartbeat. The failed pings are logged, but they never fail the run.
+func (h *Heartbeat) ping(ctx context.Context, suffix, body string) {
+	var r io.Reader
+	if h.method != http.MethodGet && body != "" {
+		r = strings.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, h.method, h.url+suffix, r)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to ping heartbeat", "error", err)
+		return
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to ping heartbeat", "error", err)
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		slog.WarnContext(ctx, "failed to ping heartbeat", "error", fmt.Errorf("unexpected response status %s", resp.Status))
+	}
+}
+
+// health reports whether the daemon's status updates are succeeding. The daemon is live, while its last
+// successful status change isn't older than the configured max age, or if the updates were paused or overridden.
+// It is ready after the first successful status change, for as long as it's live. The updates, that kept the status
+// in degraded mode, e.g. with degraded.on_error: keep, do what the daemon is configured to do, so they keep it live
+// and ready, but report it degraded.
+func (d *Daemon) health(now time.Time) (live, ready bool, reason string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	maxAge := d.cfg.Health.MaxAge
+	if now.Before(d.pausedUntil) || (d.override != nil && now.Before(d.override.ExpiresAt)) {
+		return true, !d.lastSuccess.IsZero(), "updates are paused"
+	}
+	if d.lastKept.After(d.lastSuccess) && now.Sub(d.lastKept) <= maxAge {
+		return true, !d.lastSuccess.IsZero(), "degraded: weather is unavailable, the status is kept"
+	}
+	if d.lastSuccess.IsZero() {
+		if now.Sub(d.startedAt) > maxAge {
+			return false, false, fmt.Sprintf("no successful status update since start %s ago", now.Sub(d.startedAt).Round(time.Second))
+		}
+		return true, false, "waiting for the first status update"
+	}
+	if age := now.Sub(d.lastSuccess); age > maxAge {
+		return false, false, fmt.Sprintf("last successful status update %s ago", age.Round(time.Second))
+	}
+	return true, true, "ok"
+}
+
+func (d *Daemon) handleLive(w http.ResponseWriter, r *http.Request) {
+	live, _, reason := d.health(time.Now())
+	writeHealth(w, live, reason)
+}
+
+func (d *Daemon) handleReady(w http.ResponseWriter, r *http.Request) {
+	_, ready, reason := d.health(time.Now())
+	writeHealth(w, ready, reason)
+}
+
+func writeHealth(w http.ResponseWriter, ok bool, reason string) {
+	code := http.StatusOK
+	if !ok {
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(code)
+	fmt.Fprintln(w, reason)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeartbeat(t *testing.T) {
+	var pings []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		pings = append(pings, strings.TrimSpace(r.Method+" "+r.URL.Path+" "+string(body)))
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	hb := NewHeartbeat(ts.URL+"/0123-abcd/", "", nil)
+	hb.Start(ctx)
+	hb.Done(ctx, nil)
+	hb.Start(ctx)
+	hb.Done(ctx, errors.New("weather API request failed"))
+
+	NewHeartbeat(ts.URL+"/0123-abcd", "get", nil).Done(ctx, errors.New("failed"))
+
+	want := []string{
+		"POST /0123-abcd/start",
+		"POST /0123-abcd",
+		"POST /0123-abcd/start",
+		"POST /0123-abcd/fail weather API request failed",
+		"GET /0123-abcd/fail",
+	}
+	if strings.Join(pings, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Heartbeat: want pings %q, got %q", want, pings)
+	}
+}
+
+func TestDaemon_health(t *testing.T) {
+	start := time.Date(2020, 4, 23, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name        string
+		lastSuccess time.Time
+		lastKept    time.Time
+		pausedUntil time.Time
+		now         time.Time
+		wantLive    bool
+		wantReady   bool
+	}{
+		{"starting", time.Time{}, time.Time{}, time.Time{}, start.Add(time.Minute), true, false},
+		{"never succeeded", time.Time{}, time.Time{}, time.Time{}, start.Add(time.Hour), false, false},
+		{"updated", start.Add(20 * time.Minute), time.Time{}, time.Time{}, start.Add(30 * time.Minute), true, true},
+		{"stale", start.Add(20 * time.Minute), time.Time{}, time.Time{}, start.Add(time.Hour), false, false},
+		{"paused", start.Add(20 * time.Minute), time.Time{}, start.Add(2 * time.Hour), start.Add(time.Hour), true, true},
+		{"kept", start.Add(20 * time.Minute), start.Add(50 * time.Minute), time.Time{}, start.Add(time.Hour), true, true},
+		{"kept before start", time.Time{}, start.Add(50 * time.Minute), time.Time{}, start.Add(time.Hour), true, false},
+		{"kept long ago", start.Add(20 * time.Minute), start.Add(50 * time.Minute), time.Time{}, start.Add(2 * time.Hour), false, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var cfg Config
+			cfg.Health.MaxAge = 30 * time.Minute
+
+			d := NewDaemon(cfg, nil, nil)
+			d.startedAt = start
+			d.lastSuccess = tc.lastSuccess
+			d.lastKept = tc.lastKept
+			d.pausedUntil = tc.pausedUntil
+
+			live, ready, reason := d.health(tc.now)
+			if live != tc.wantLive || ready != tc.wantReady {
+				t.Errorf("Daemon.health: want live %v, ready %v, got %v, %v (%s)", tc.wantLive, tc.wantReady, live, ready, reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const redacted = "xxxxx"
+
+// redactor scrubs the secrets, the program knows about, from every log record.
+var redactor = &Redactor{}
+
+// Redactor replaces API tokens and keys in strings. Besides the secrets, registered with AddSecret,
+// it recognises the common patterns of credentials in HTTP headers, URLs and GitHub tokens.
+type Redactor struct {
+	mu      sync.RWMutex
+	secrets []string
+}
+
+var redactPatterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	// Headers, as dumped by the graphql client in debug mode.
+	{regexp.MustCompile(`(?i)(Authorization:\[)[^\]]*`), "${1}" + redacted},
+	{regexp.MustCompile(`(?i)\b(bearer|token|basic)(\s+)[A-Za-z0-9_.~+/=-]{16,}`), "${1}${2}" + redacted},
+	// Credentials in URL query, e.g. OpenWeather API key in "appid".
+	{regexp.MustCompile(`(?i)([?&](?:appid|api_?key|access_token|token|key)=)[^&\s"]+`), "${1}" + redacted},
+	// GitHub tokens, see https://github.blog/2021-04-05-behind-githubs-new-authentication-token-formats/
+	{regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{20,}|github_pat_[A-Za-z0-9_]{20,})`), redacted},
+}
+
+// AddSecret registers a secret value, that must never appear in the logs.
+func (r *Redactor) AddSecret(secret string) {
+	if len(secret) < 4 {
+		// Replacing very short strings would mangle the logs, without protecting anything.
+		return
+	}
+	r.mu.Lock()
+	r.secrets = append(r.secrets, secret)
+	r.mu.Unlock()
+}
+
+func (r *Redactor) Redact(s string) string {
+	r.mu.RLock()
+	for _, secret := range r.secrets {
+		s = strings.Replace(s, secret, redacted, -1)
+	}
+	r.mu.RUnlock()
+
+	for _, p := range redactPatterns {
+		s = p.re.ReplaceAllString(s, p.repl)
+	}
+	return s
+}
+
+// redactHandler is a slog.Handler, that redacts the message and the attributes of every record,
+// before passing it to the next handler.
+type redactHandler struct {
+	next     slog.Handler
+	redactor *Redactor
+}
+
+func (h *redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	nr := slog.NewRecord(r.Time, r.Level, h.redactor.Redact(r.Message), r.PC)
+	if id, ok := ctx.Value(runIDKey{}).(string); ok {
+		nr.AddAttrs(slog.String("run_id", id))
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		nr.AddAttrs(h.redactAttr(a))
+		return true
+	})
+	return h.next.Handle(ctx, nr)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	ra := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		ra[i] = h.redactAttr(a)
+	}
+	return &redactHandler{next: h.next.WithAttrs(ra), redactor: h.redactor}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{next: h.next.WithGroup(name), redactor: h.redactor}
+}
+
+func (h *redactHandler) redactAttr(a slog.Attr) slog.Attr {
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, h.redactor.Redact(v.String()))
+	case slog.KindGroup:
+		attrs := v.Group()
+		ra := make([]slog.Attr, len(attrs))
+		for i, ga := range attrs {
+			ra[i] = h.redactAttr(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(ra...)}
+	case slog.KindAny:
+		// Errors and arbitrary values are formatted the same way the text handler would, and redacted as strings.
+		return slog.String(a.Key, h.redactor.Redact(fmt.Sprintf("%+v", v.Any())))
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
+
+// newLogger creates the logger, writing records in "text" or "json" format.
+func newLogger(w io.Writer, format string, level slog.Level) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level}
+
+	var h slog.Handler
+	switch format {
+	case "", "text":
+		h = slog.NewTextHandler(w, opts)
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+	return slog.New(&redactHandler{next: h, redactor: redactor}), nil
+}
+
+// setupLogging replaces the default logger, according to the configuration, and registers the configured secrets
+// for redaction.
+func setupLogging(w io.Writer, cfg Config, debug bool) error {
+	redactor.AddSecret(cfg.GitHub.Token)
+	redactor.AddSecret(cfg.OWM.ApiKey)
+	redactor.AddSecret(cfg.Daemon.APIToken)
+	redactor.AddSecret(cfg.Health.HeartbeatURL)
+	for _, proxy := range []string{cfg.GitHub.HTTP.Proxy, cfg.OWM.HTTP.Proxy} {
+		if u, err := url.Parse(proxy); err == nil {
+			if password, ok := u.User.Password(); ok {
+				redactor.AddSecret(password)
+			}
+		}
+	}
+
+	level := slog.LevelInfo
+	if cfg.Log.Level != "" {
+		if err := level.UnmarshalText([]byte(cfg.Log.Level)); err != nil {
+			return fmt.Errorf("error parsing log level: %v", err)
+		}
+	}
+	if debug {
+		level = slog.LevelDebug
+	}
+
+	logger, err := newLogger(w, cfg.Log.Format, level)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
+
+type runIDKey struct{}
+
+// withRunID returns the context, which log records are marked with a new unique run ID.
+func withRunID(ctx context.Context) context.Context {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return context.WithValue(ctx, runIDKey{}, hex.EncodeToString(b))
+}
+
+// debugLog returns the logger of the graphql client's debug output, that logs with the context, e.g. its run ID.
+func debugLog(ctx context.Context) func(string) {
+	return func(s string) {
+		slog.DebugContext(ctx, s)
+	}
+}
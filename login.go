@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const credentialsFileName = "credentials.json"
+
+// defaultCredentialsFile is where the login command stores the tokens, unless configured otherwise.
+func defaultCredentialsFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = defaultStateDir()
+	}
+	return filepath.Join(dir, progName, credentialsFileName)
+}
+
+// Credentials are the API tokens, stored by the login command, by GitHub host.
+type Credentials map[string]StoredToken
+
+type StoredToken struct {
+	Token     string    `json:"token"`
+	Scopes    string    `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// readCredentials reads the credentials file. A missing file means no credentials.
+func readCredentials(path string) (Credentials, error) {
+	creds := make(Credentials)
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return creds, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading credentials file: %v", err)
+	}
+
+	if fi, err := os.Stat(path); err == nil && runtime.GOOS != "windows" && fi.Mode().Perm()&0077 != 0 {
+		slog.Warn("credentials file is accessible by other users", "path", path, "mode", fi.Mode().Perm())
+	}
+
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("error parsing credentials file %q: %v", path, err)
+	}
+	return creds, nil
+}
+
+// writeCredentials replaces the credentials file, that only the user can read. The file is removed,
+// when there are no credentials left.
+func writeCredentials(path string, creds Credentials) error {
+	if len(creds) == 0 {
+		err := os.Remove(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	data, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0600)
+}
+
+// loadStoredToken sets the configuration's token to the one, stored by login for the configured GitHub host.
+func loadStoredToken(cfg *Config) error {
+	host, err := githubHost(cfg.GitHub.Endpoint)
+	if err != nil {
+		// The invalid endpoint fails the configuration validation.
+		return nil
+	}
+	creds, err := readCredentials(cfg.GitHub.CredentialsFile)
+	if err != nil {
+		return err
+	}
+	cfg.GitHub.Token = creds[host].Token
+	return nil
+}
+
+// githubHost returns the host of GitHub's web interface, derived from the GraphQL API endpoint.
+// The tokens are stored by it, and the device flow runs against it.
+func githubHost(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid github endpoint %q", endpoint)
+	}
+	host := strings.ToLower(u.Host)
+	if host == "api.github.com" {
+		return "github.com", nil
+	}
+	return host, nil
+}
+
+// githubWebURL returns the URL of GitHub's web interface, derived from the GraphQL API endpoint.
+func githubWebURL(endpoint string) (string, error) {
+	host, err := githubHost(endpoint)
+	if err != nil {
+		return "", err
+	}
+	u, _ := url.Parse(endpoint)
+	return u.Scheme + "://" + host, nil
+}
+
+// DeviceFlow authorizes the program with an OAuth app, using the device authorization flow, see
+// https://docs.github.com/en/apps/oauth-apps/building-oauth-apps/authorizing-oauth-apps#device-flow
+type DeviceFlow struct {
+	baseURL  string
+	clientID string
+	client   *http.Client
+	// interval is the time between the token requests, when the server doesn't set it.
+	interval time.Duration
+}
+
+// defaultDeviceFlowInterval is the time between the token requests, when the server doesn't set it, see
+// RFC 8628, section 3.2.
+const defaultDeviceFlowInterval = 5 * time.Second
+
+func NewDeviceFlow(baseURL, clientID string, client *http.Client) *DeviceFlow {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &DeviceFlow{
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		clientID: clientID,
+		client:   client,
+		interval: defaultDeviceFlowInterval,
+	}
+}
+
+// DeviceCode is the code, the user enters at the verification URI to authorize the program.
+type DeviceCode struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationURI string `json:"verification_uri"`
+	ExpiresIn       int    `json:"expires_in"`
+	Interval        int    `json:"interval"`
+}
+
+// OAuthToken is the token, granted to the program.
+type OAuthToken struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	Scope       string `json:"scope"`
+}
+
+type oauthResponse struct {
+	OAuthToken
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+	Interval         int    `json:"interval"`
+}
+
+// Start requests the device and user codes for the scopes.
+func (f *DeviceFlow) Start(ctx context.Context, scope string) (DeviceCode, error) {
+	var dc DeviceCode
+	err := f.post(ctx, "/login/device/code", url.Values{
+		"client_id": {f.clientID},
+		"scope":     {scope},
+	}, &dc)
+	if err != nil {
+		return dc, err
+	}
+	if dc.DeviceCode == "" {
+		return dc, fmt.Errorf("device code request failed: empty device code")
+	}
+	return dc, nil
+}
+
+// Wait polls for the token, until the user authorizes the program, or the device code expires.
+func (f *DeviceFlow) Wait(ctx context.Context, dc DeviceCode) (OAuthToken, error) {
+	if dc.ExpiresIn > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(dc.ExpiresIn)*time.Second)
+		defer cancel()
+	}
+
+	interval := time.Duration(dc.Interval) * time.Second
+	if interval <= 0 {
+		interval = f.interval
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return OAuthToken{}, fmt.Errorf("device code expired, run login again")
+			}
+			return OAuthToken{}, ctx.Err()
+		case <-time.After(interval):
+		}
+
+		var resp oauthResponse
+		err := f.post(ctx, "/login/oauth/access_token", url.Values{
+			"client_id":   {f.clientID},
+			"device_code": {dc.DeviceCode},
+			"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+		}, &resp)
+		if err != nil {
+			return OAuthToken{}, err
+		}
+
+		switch resp.Error {
+		case "":
+			if resp.AccessToken == "" {
+				return OAuthToken{}, fmt.Errorf("token request failed: empty access token")
+			}
+			return resp.OAuthToken, nil
+		case "authorization_pending":
+		case "slow_down":
+			if resp.Interval > 0 {
+				interval = time.Duration(resp.Interval) * time.Second
+			} else {
+				interval += 5 * time.Second
+			}
+		case "expired_token":
+			return OAuthToken{}, fmt.Errorf("device code expired, run login again")
+		case "access_denied":
+			return OAuthToken{}, fmt.Errorf("authorization was denied")
+		default:
+			return OAuthToken{}, fmt.Errorf("token request failed: %s: %s", resp.Error, resp.ErrorDescription)
+		}
+	}
+}
+
+func (f *DeviceFlow) post(ctx context.Context, path string, form url.Values, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.baseURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("oauth request failed: unexpected response status %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("error parsing oauth response: %v", err)
+	}
+	return nil
+}
+
+func runLogin(ctx context.Context, args []string) error {
+	var (
+		debug      bool
+		configPath string
+		clientID   string
+	)
+
+	flags := newFlagSet("login", "Authorize the program on GitHub and store the token")
+	flags.BoolVar(&debug, "debug", false, "Enable debug logging")
+	flags.StringVar(&configPath, "configuration", "config.yaml", "Path to configuration file")
+	flags.StringVar(&clientID, "client-id", "", "Client ID of the OAuth app, overrides github.oauth_client_id")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadCommandConfig(configPath, debug)
+	if err != nil {
+		return err
+	}
+	if clientID == "" {
+		clientID = cfg.GitHub.OAuthClientID
+	}
+	if clientID == "" {
+		return fmt.Errorf("oauth client id is empty, set github.oauth_client_id to the client ID of an OAuth app with device flow enabled")
+	}
+
+	host, err := githubHost(cfg.GitHub.Endpoint)
+	if err != nil {
+		return err
+	}
+	webURL, err := githubWebURL(cfg.GitHub.Endpoint)
+	if err != nil {
+		return err
+	}
+	httpClient, err := newHTTPClient(cfg.GitHub.HTTP)
+	if err != nil {
+		return fmt.Errorf("error configuring github client: %v", err)
+	}
+
+	flow := NewDeviceFlow(webURL, clientID, httpClient)
+	dc, err := flow.Start(ctx, "user")
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "First copy your one-time code: %s\nThen open %s in your browser to authorize %s.\n", dc.UserCode, dc.VerificationURI, progName)
+
+	token, err := flow.Wait(ctx, dc)
+	if err != nil {
+		return err
+	}
+	redactor.AddSecret(token.AccessToken)
+
+	creds, err := readCredentials(cfg.GitHub.CredentialsFile)
+	if err != nil {
+		return err
+	}
+	creds[host] = StoredToken{
+		Token:     token.AccessToken,
+		Scopes:    token.Scope,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := writeCredentials(cfg.GitHub.CredentialsFile, creds); err != nil {
+		return fmt.Errorf("error saving credentials: %v", err)
+	}
+
+	fmt.Printf("logged in to %s, token stored in %s\n", host, cfg.GitHub.CredentialsFile)
+	return nil
+}
+
+func runLogout(ctx context.Context, args []string) error {
+	var configPath string
+
+	flags := newFlagSet("logout", "Delete the token, stored by login")
+	flags.StringVar(&configPath, "configuration", "config.yaml", "Path to configuration file")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadCommandConfig(configPath, false)
+	if err != nil {
+		return err
+	}
+
+	host, err := githubHost(cfg.GitHub.Endpoint)
+	if err != nil {
+		return err
+	}
+	creds, err := readCredentials(cfg.GitHub.CredentialsFile)
+	if err != nil {
+		return err
+	}
+	if _, ok := creds[host]; !ok {
+		fmt.Printf("not logged in to %s\n", host)
+		return nil
+	}
+	delete(creds, host)
+	if err := writeCredentials(cfg.GitHub.CredentialsFile, creds); err != nil {
+		return fmt.Errorf("error saving credentials: %v", err)
+	}
+
+	fmt.Printf("logged out of %s, the token stays valid until it's revoked in GitHub's settings\n", host)
+	return nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestDeviceFlow(t *testing.T) {
+	var polls int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if got := r.Form.Get("client_id"); got != "Iv1.client" {
+			t.Errorf("request %s: want client_id %q, got %q", r.URL.Path, "Iv1.client", got)
+		}
+		switch r.URL.Path {
+		case "/login/device/code":
+			w.Write([]byte(`{"device_code":"dc","user_code":"WDJB-MJHT","verification_uri":"https://github.com/login/device","expires_in":900,"interval":0}`))
+		case "/login/oauth/access_token":
+			if got := r.Form.Get("device_code"); got != "dc" {
+				t.Errorf("request %s: want device_code %q, got %q", r.URL.Path, "dc", got)
+			}
+			polls++
+			if polls < 3 {
+				w.Write([]byte(`{"error":"authorization_pending"}`))
+				return
+			}
+			w.Write([]byte(`{"access_token":"gho_token","token_type":"bearer","scope":"user"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	flow := NewDeviceFlow(ts.URL, "Iv1.client", nil)
+	flow.interval = time.Millisecond
+	dc, err := flow.Start(context.Background(), "user")
+	if err != nil {
+		t.Fatalf("DeviceFlow.Start: unexpected error %v", err)
+	}
+	if want := "WDJB-MJHT"; dc.UserCode != want {
+		t.Errorf("DeviceFlow.Start: want user code %q, got %q", want, dc.UserCode)
+	}
+
+	token, err := flow.Wait(context.Background(), dc)
+	if err != nil {
+		t.Fatalf("DeviceFlow.Wait: unexpected error %v", err)
+	}
+	if want := "gho_token"; token.AccessToken != want {
+		t.Errorf("DeviceFlow.Wait: want token %q, got %q", want, token.AccessToken)
+	}
+	if polls != 3 {
+		t.Errorf("DeviceFlow.Wait: want 3 polls, got %d", polls)
+	}
+}
+
+func TestDeviceFlow_Wait_defaultInterval(t *testing.T) {
+	var polls int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		w.Write([]byte(`{"error":"authorization_pending"}`))
+	}))
+	defer ts.Close()
+
+	// The server didn't set the interval, so the flow waits the default 5 seconds before the first poll.
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	flow := NewDeviceFlow(ts.URL, "Iv1.client", nil)
+	if _, err := flow.Wait(ctx, DeviceCode{DeviceCode: "dc"}); err == nil {
+		t.Error("DeviceFlow.Wait: want error on the context's deadline, got nil")
+	}
+	if polls != 0 {
+		t.Errorf("DeviceFlow.Wait: want no polls within the default interval, got %d", polls)
+	}
+}
+
+func TestCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), progName, credentialsFileName)
+
+	creds, err := readCredentials(path)
+	if err != nil || len(creds) != 0 {
+		t.Fatalf("readCredentials: missing file, want no credentials, got %v, %v", creds, err)
+	}
+
+	creds["github.com"] = StoredToken{Token: "gho_token", Scopes: "user"}
+	if err := writeCredentials(path, creds); err != nil {
+		t.Fatalf("writeCredentials: %v", err)
+	}
+	if fi, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if runtime.GOOS != "windows" && fi.Mode().Perm() != 0600 {
+		t.Errorf("writeCredentials: want mode 0600, got %v", fi.Mode().Perm())
+	}
+
+	var cfg Config
+	cfg.GitHub.Endpoint = defaultGitHubAPIEndpoint
+	cfg.GitHub.CredentialsFile = path
+	if err := loadStoredToken(&cfg); err != nil {
+		t.Fatalf("loadStoredToken: %v", err)
+	}
+	if want := "gho_token"; cfg.GitHub.Token != want {
+		t.Errorf("loadStoredToken: want %q, got %q", want, cfg.GitHub.Token)
+	}
+
+	delete(creds, "github.com")
+	if err := writeCredentials(path, creds); err != nil {
+		t.Fatalf("writeCredentials: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("writeCredentials: no credentials, want file removed, got %v", err)
+	}
+}
+
+func TestGitHubWebURL(t *testing.T) {
+	cases := []struct {
+		endpoint string
+		want     string
+	}{
+		{"https://api.github.com/graphql", "https://github.com"},
+		{"https://github.example.com/api/graphql", "https://github.example.com"},
+		{"http://localhost:8080/api/graphql", "http://localhost:8080"},
+	}
+
+	for n, tc := range cases {
+		t.Run(fmt.Sprintf("case=%d", n), func(t *testing.T) {
+			got, err := githubWebURL(tc.endpoint)
+			if err != nil {
+				t.Fatalf("githubWebURL: unexpected error %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("githubWebURL: want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
@@ -2,20 +2,16 @@ package main
 
 import (
 	"context"
-	"encoding/json"
-	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
-	"regexp"
-	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
-	"github.com/machinebox/graphql"
 	"gopkg.in/yaml.v2"
 )
 
@@ -23,23 +19,116 @@ const (
 	defaultOWMAPIEndpoint    = "https://api.openweathermap.org/data/2.5/weather?appid={api-key}&units=metric"
 	defaultGitHubAPIEndpoint = "https://api.github.com/graphql"
 	defaultGitHubClientID    = "github/weather"
+	defaultDaemonInterval    = 10 * time.Minute
 )
 
 type Config struct {
-	ExpirationTime uint8 `yaml:"expiration_time"`
-	GitHub         struct {
+	ExpirationTime uint8  `yaml:"expiration_time"`
+	StateDir       string `yaml:"state_dir"`
+	Cache          struct {
+		// TTL is how long the weather is reused, before it's requested again.
+		TTL      time.Duration `yaml:"ttl"`
+		Disabled bool          `yaml:"disabled"`
+	} `yaml:"cache"`
+	// FeelsLike is the model of the "feels like" temperature in the status: "provider", "apparent", "heat_index",
+	// "wind_chill" or "humidex". Empty hides it.
+	FeelsLike string `yaml:"feels_like"`
+	// SunriseSunset shows 🌅 and 🌇 around sunrise and sunset, when the sky is clear.
+	SunriseSunset bool `yaml:"sunrise_sunset"`
+	// Moonrise shows 🌌 instead of the moon's phase on the clear nights, when the moon is below the horizon.
+	Moonrise bool `yaml:"moonrise"`
+	// Wind configures the wind in the status.
+	Wind struct {
+		// Segments are the wind segments of the message: "beaufort", "force", "arrow" and "gust".
+		Segments []string `yaml:"segments"`
+		// WindyBeaufort is the wind force, from which the windy emoji replaces the weather's. 13 disables it.
+		WindyBeaufort int `yaml:"windy_beaufort"`
+		// GustBeaufort is the gusts force, worth a warning.
+		GustBeaufort int `yaml:"gust_beaufort"`
+	} `yaml:"wind"`
+	// Degraded configures what the program does, when it can't get the weather.
+	Degraded struct {
+		// MaxAge is how old the last good weather can be, to be used instead. Zero disables it.
+		MaxAge time.Duration `yaml:"max_age"`
+		// Marker is written before the temperature of the last good weather, e.g. "~" for "~+9°".
+		Marker string `yaml:"marker"`
+		// OnError is "fail", the default, or "keep" to leave the current status untouched.
+		OnError string `yaml:"on_error"`
+	} `yaml:"degraded"`
+	Daemon struct {
+		Interval    time.Duration `yaml:"interval"`
+		ClearOnExit bool          `yaml:"clear_on_exit"`
+		Listen      string        `yaml:"listen"`
+		APIToken    string        `yaml:"api_token"`
+	} `yaml:"daemon"`
+	Health struct {
+		MaxAge          time.Duration `yaml:"max_age"`
+		HeartbeatURL    string        `yaml:"heartbeat_url"`
+		HeartbeatMethod string        `yaml:"heartbeat_method"`
+		HTTP            HTTPConfig    `yaml:"http"`
+	} `yaml:"health"`
+	Log struct {
+		Format string `yaml:"format"`
+		Level  string `yaml:"level"`
+	} `yaml:"log"`
+	Tracing struct {
+		Endpoint    string     `yaml:"endpoint"`
+		ServiceName string     `yaml:"service_name"`
+		HTTP        HTTPConfig `yaml:"http"`
+	} `yaml:"tracing"`
+	Metrics struct {
+		Pushgateway string     `yaml:"pushgateway"`
+		Job         string     `yaml:"job"`
+		HTTP        HTTPConfig `yaml:"http"`
+	} `yaml:"metrics"`
+	GitHub struct {
 		ClientID string `yaml:"client_id"`
 		Endpoint string `yaml:"endpoint"`
 		Token    string `yaml:"token"`
+		// Organization is the login of the organization, which members only are allowed to see the status.
+		Organization string     `yaml:"organization"`
+		HTTP         HTTPConfig `yaml:"http"`
+		// OAuthClientID is the client ID of the OAuth app, the login command authorizes the program with.
+		OAuthClientID string `yaml:"oauth_client_id"`
+		// TokenSource is where the token comes from, when not set: "gh" reuses the token of GitHub CLI.
+		TokenSource string `yaml:"token_source"`
+		// CredentialsFile is where the login command stores the token, used when Token is empty.
+		CredentialsFile string `yaml:"credentials_file"`
 	} `yaml:"github"`
 	OWM struct {
-		ApiKey   string `yaml:"api_key"`
-		Endpoint string `yaml:"endpoint"`
-		Query    string `yaml:"query"`
+		ApiKey   string     `yaml:"api_key"`
+		Endpoint string     `yaml:"endpoint"`
+		Query    string     `yaml:"query"`
+		HTTP     HTTPConfig `yaml:"http"`
+		// Quota is the budget of API calls, shared by all invocations of the program.
+		Quota struct {
+			PerMinute int  `yaml:"per_minute"`
+			PerDay    int  `yaml:"per_day"`
+			Disabled  bool `yaml:"disabled"`
+		} `yaml:"quota"`
+		// Validation rejects the observations, older than MaxAge, or with implausible values.
+		Validation struct {
+			MaxAge   time.Duration `yaml:"max_age"`
+			Disabled bool          `yaml:"disabled"`
+		} `yaml:"validation"`
 	} `yaml:"owm"`
 }
 
 func ConfigFromFile(configPath string) (Config, error) {
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return cfg, err
+	}
+
+	if err := validateConfig(cfg); err != nil {
+		return cfg, fmt.Errorf("error validating configuration file: %v", err)
+	}
+
+	return cfg, nil
+}
+
+// loadConfig parses the configuration file and fills in the defaults, without validating it.
+func loadConfig(configPath string) (Config, error) {
 	f, err := os.Open(configPath)
 	if err != nil {
 		return Config{}, fmt.Errorf("error opening configuration file %q: %v", configPath, err)
@@ -57,287 +146,165 @@ func ConfigFromFile(configPath string) (Config, error) {
 
 	if cfg.GitHub.Endpoint == "" {
 		cfg.GitHub.Endpoint = defaultGitHubAPIEndpoint
+	} else {
+		cfg.GitHub.Endpoint = githubGraphQLURL(cfg.GitHub.Endpoint)
 	}
 
-	if cfg.GitHub.ClientID == "" {
-		cfg.GitHub.ClientID = defaultGitHubClientID
+	if cfg.GitHub.CredentialsFile == "" {
+		cfg.GitHub.CredentialsFile = defaultCredentialsFile()
 	}
 
-	if cfg.ExpirationTime == 0 {
-		cfg.ExpirationTime = 30
-	}
-
-	if err := validateConfig(cfg); err != nil {
-		return cfg, fmt.Errorf("error validating configuration file: %v", err)
+	// The token of GitHub CLI is read only by the commands, that call GitHub API, see newGitHubClient.
+	if cfg.GitHub.TokenSource != tokenSourceGH && cfg.GitHub.Token == "" {
+		if err := loadStoredToken(&cfg); err != nil {
+			return cfg, err
+		}
 	}
 
-	return cfg, nil
-}
-
-func validateConfig(cfg Config) error {
-	if cfg.OWM.ApiKey == "" {
-		return fmt.Errorf("owm api key is empty")
+	// The traces and the heartbeats mustn't hold up the runs for long.
+	if cfg.Tracing.HTTP.Timeout == 0 {
+		cfg.Tracing.HTTP.Timeout = defaultTracingTimeout
 	}
-	if cfg.GitHub.Token == "" {
-		return fmt.Errorf("github api token is empty")
+	if cfg.Health.HTTP.Timeout == 0 {
+		cfg.Health.HTTP.Timeout = defaultHeartbeatTimeout
 	}
-	return nil
-}
 
-func main() {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	sigc := make(chan os.Signal, 2)
-	signal.Notify(sigc, os.Interrupt, syscall.SIGTERM)
-
-	go func() {
-		<-sigc
-		cancel()
-	}()
-
-	if err := run(ctx, os.Args[1:]); err != nil {
-		log.Fatal(err)
+	for _, hc := range []*HTTPConfig{&cfg.GitHub.HTTP, &cfg.OWM.HTTP, &cfg.Metrics.HTTP, &cfg.Tracing.HTTP, &cfg.Health.HTTP} {
+		if hc.Timeout == 0 {
+			hc.Timeout = defaultHTTPTimeout
+		}
+		if hc.ConnectTimeout == 0 {
+			hc.ConnectTimeout = defaultConnectTimeout
+		}
 	}
-}
-
-func run(ctx context.Context, args []string) error {
-	var (
-		debug      bool
-		configPath string
-	)
-
-	flags := flag.NewFlagSet("", flag.ExitOnError)
-	flags.BoolVar(&debug, "debug", false, "Enable debug logging")
-	flags.StringVar(&configPath, "configuration", "config.yaml", "Path to configuration file")
 
-	if err := flags.Parse(args); err != nil {
-		return err
+	if cfg.GitHub.ClientID == "" {
+		cfg.GitHub.ClientID = defaultGitHubClientID
 	}
 
-	cfg, err := ConfigFromFile(configPath)
-	if err != nil {
-		return err
+	if cfg.ExpirationTime == 0 {
+		cfg.ExpirationTime = 30
 	}
 
-	owm := NewOWMClient(cfg.OWM.Endpoint, cfg.OWM.ApiKey)
-	gh := NewGitHubClient(cfg.GitHub.Endpoint, cfg.GitHub.Token)
-	if debug {
-		gh.client.Log = debugLog
+	if cfg.StateDir == "" {
+		cfg.StateDir = defaultStateDir()
 	}
 
-	wr, err := owm.Weather(ctx, cfg.OWM.Query)
-	if err != nil {
-		return err
+	if cfg.OWM.Validation.MaxAge == 0 {
+		cfg.OWM.Validation.MaxAge = defaultMaxObservationAge
 	}
 
-	log.Printf("got owm response: %+v\n", wr)
-
-	status := ChangeUserStatusInput{
-		ClientMutationID: cfg.GitHub.ClientID,
-		Emoji:            wr.Emoji(),
-		Message:          wr.ShortString(),
-		ExpiresAt:        time.Now().UTC().Add(time.Duration(cfg.ExpirationTime) * time.Minute),
+	if cfg.OWM.Quota.PerMinute == 0 {
+		cfg.OWM.Quota.PerMinute = defaultOWMCallsPerMinute
 	}
-	sr, err := gh.ChangeUserStatus(ctx, status)
-	if err != nil {
-		return err
-	}
-
-	log.Printf("set gh status: %+v\n", sr)
 
-	return nil
-}
-
-type OWMClient struct {
-	apiURL string
-	client *http.Client
-}
-
-func NewOWMClient(apiURL, apiKey string) *OWMClient {
-	return &OWMClient{
-		apiURL: strings.Replace(apiURL, "{api-key}", apiKey, 1),
-		client: &http.Client{},
+	if cfg.OWM.Quota.PerDay == 0 {
+		cfg.OWM.Quota.PerDay = defaultOWMCallsPerDay
 	}
-}
-
-type WeatherResponse struct {
-	Cod     int    `json:"cod"`
-	ID      int    `json:"id"`
-	Name    string `json:"name"`
-	Weather []struct {
-		ID          int    `json:"id"`
-		Main        string `json:"main"`
-		Description string `json:"description"`
-		Icon        string `json:"icon"`
-	} `json:"weather"`
-	Main struct {
-		Temp      float64 `json:"temp"`
-		FeelsLike float64 `json:"feels_like"`
-	} `json:"main"`
-}
-
-func (wr WeatherResponse) ShortString() string {
-	var s strings.Builder
 
-	s.WriteString(wr.Name)
-	s.WriteByte(',')
-	s.WriteByte(' ')
+	if cfg.Cache.TTL == 0 {
+		cfg.Cache.TTL = defaultCacheTTL
+	}
 
-	if wr.Main.Temp > 0 {
-		s.WriteByte('+')
+	if cfg.Metrics.Job == "" {
+		cfg.Metrics.Job = defaultMetricsJob
 	}
-	s.WriteString(strconv.FormatFloat(wr.Main.Temp, 'f', 0, 64))
-	s.WriteString("°") // WriteString as "degree" is not from ASCII
 
-	return s.String()
-}
+	if cfg.Tracing.ServiceName == "" {
+		cfg.Tracing.ServiceName = defaultTracingServiceName
+	}
 
-// Emoji maps OpenWeather weather status to emojis.
-// See https://openweathermap.org/weather-conditions
-func (wr WeatherResponse) Emoji() string {
-	if len(wr.Weather) == 0 {
-		return ":zap:"
+	if cfg.Daemon.Interval == 0 {
+		cfg.Daemon.Interval = defaultDaemonInterval
 	}
 
-	w := wr.Weather[0]
-	if w.ID == 800 {
-		if w.Icon == "01n" {
-			return ":full_moon:"
-		}
-		return ":sunny:"
-	}
-	if w.ID > 800 {
-		switch w.ID {
-		case 801:
-			return "🌤️"
-		case 802:
-			return ":cloud:"
-		default:
-			return ":partly_sunny:"
-		}
-	} else if w.ID >= 700 {
-		return ":foggy:"
-	} else if w.ID >= 600 {
-		return ":snowflake:"
-	} else if w.ID >= 500 {
-		if w.ID == 500 {
-			return "🌦️"
-		}
-		if w.ID >= 511 {
-			return "🌨️"
-		}
-		return "☔"
-	} else if w.ID >= 300 {
-		return "🌦️"
-	} else if w.ID >= 200 {
-		return "⛈️"
+	if cfg.Health.MaxAge == 0 {
+		// Tolerate a couple of failed updates in a row.
+		cfg.Health.MaxAge = 3 * cfg.Daemon.Interval
 	}
 
-	return ":zap:"
+	return cfg, nil
 }
 
-func (c *OWMClient) Weather(ctx context.Context, query string) (WeatherResponse, error) {
-	u := c.apiURL + "&q=" + query
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
-	if err != nil {
-		return WeatherResponse{}, err
+func validateConfig(cfg Config) error {
+	if err := validateOWMConfig(cfg); err != nil {
+		return err
 	}
-
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return WeatherResponse{}, fmt.Errorf("weather API request failed, query %q: %w", query, err)
+	if cfg.OWM.Quota.PerMinute < 0 || cfg.OWM.Quota.PerDay < 0 {
+		return fmt.Errorf("owm quota is negative")
 	}
-	defer resp.Body.Close()
-
-	var wr WeatherResponse
-	if err := json.NewDecoder(resp.Body).Decode(&wr); err != nil {
-		return WeatherResponse{}, err
+	if cfg.Daemon.Interval < 0 {
+		return fmt.Errorf("daemon interval is negative")
 	}
-
-	if wr.Cod != 200 {
-		return WeatherResponse{}, fmt.Errorf("weather API bad response, for %q: %+v", query, wr)
+	if err := validateFeelsLikeModel(cfg.FeelsLike); err != nil {
+		return err
 	}
-
-	return wr, nil
-}
-
-type GitHubClient struct {
-	apiURL string
-	token  string
-	client *graphql.Client
-}
-
-func NewGitHubClient(apiURL, token string, opts ...graphql.ClientOption) *GitHubClient {
-	return &GitHubClient{
-		apiURL: apiURL,
-		token:  token,
-		client: graphql.NewClient(apiURL, opts...),
+	if err := validateWindSegments(cfg.Wind.Segments); err != nil {
+		return err
 	}
+	if cfg.Wind.WindyBeaufort < 0 || cfg.Wind.GustBeaufort < 0 {
+		return fmt.Errorf("wind beaufort levels are negative")
+	}
+	if cfg.Degraded.MaxAge < 0 {
+		return fmt.Errorf("degraded max_age is negative")
+	}
+	switch cfg.Degraded.OnError {
+	case "", onErrorFail, onErrorKeep:
+	default:
+		return fmt.Errorf("unknown degraded on_error policy %q, expected %s or %s", cfg.Degraded.OnError, onErrorFail, onErrorKeep)
+	}
+	switch strings.ToUpper(cfg.Health.HeartbeatMethod) {
+	case "", http.MethodGet, http.MethodPost, http.MethodHead:
+	default:
+		return fmt.Errorf("unsupported heartbeat method %q", cfg.Health.HeartbeatMethod)
+	}
+	return validateGitHubConfig(cfg)
 }
 
-type ChangeUserStatusInput struct {
-	ClientMutationID    string    `json:"clientMutationId,omitempty"`
-	Emoji               string    `json:"emoji,omitempty"`
-	ExpiresAt           time.Time `json:"expiresAt,omitempty"`
-	LimitedAvailability bool      `json:"limitedAvailability,omitempty"`
-	Message             string    `json:"message,omitempty"`
-	OrganizationID      string    `json:"organizationId,omitempty"`
-}
-
-type ChangeUserStatusResponse struct {
-	ID        string    `json:"id"`
-	UpdatedAt time.Time `json:"updatedAt"`
-	ExpiresAt time.Time `json:"expiresAt"`
+func validateOWMConfig(cfg Config) error {
+	if cfg.OWM.ApiKey == "" {
+		return fmt.Errorf("owm api key is empty")
+	}
+	return nil
 }
 
-const mutationChangeUserStatus = `
-	mutation ($status: ChangeUserStatusInput!) {
-	  changeUserStatus(input: $status) {
-		status {
-		  id
-		  updatedAt
-		  expiresAt
+func validateGitHubConfig(cfg Config) error {
+	switch cfg.GitHub.TokenSource {
+	case "":
+		if cfg.GitHub.Token == "" {
+			return fmt.Errorf("github api token is empty, set github.token or run %s login", progName)
 		}
-	  }
+	case tokenSourceGH:
+		if cfg.GitHub.Token != "" {
+			return fmt.Errorf("github token is set together with token source %q, remove one of them", tokenSourceGH)
+		}
+	default:
+		return fmt.Errorf("unknown github token source %q", cfg.GitHub.TokenSource)
 	}
-`
-
-func (c *GitHubClient) ChangeUserStatus(ctx context.Context, input ChangeUserStatusInput) (ChangeUserStatusResponse, error) {
-	req := graphql.NewRequest(mutationChangeUserStatus)
-	req.Var("status", input)
-
-	resp := struct {
-		ChangeUserStatus struct {
-			Status ChangeUserStatusResponse `json:"status"`
-		} `json:"changeUserStatus"`
-	}{}
-	if err := c.run(ctx, req, &resp); err != nil {
-		return ChangeUserStatusResponse{}, fmt.Errorf("github API request failed: %w", err)
+	if u, err := url.Parse(cfg.GitHub.Endpoint); err != nil || u.Host == "" {
+		return fmt.Errorf("invalid github endpoint %q", cfg.GitHub.Endpoint)
 	}
+	return nil
+}
 
-	status := resp.ChangeUserStatus.Status
-	if status.UpdatedAt.Before(time.Now().UTC().Add(-time.Minute)) {
-		return ChangeUserStatusResponse{}, fmt.Errorf("status not updated, github API response: %v", resp)
-	}
+func main() {
+	// Until the configuration is loaded, log in the default format, still redacting the secrets.
+	logger, _ := newLogger(os.Stderr, "text", slog.LevelInfo)
+	slog.SetDefault(logger)
 
-	return status, nil
-}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-func (c *GitHubClient) run(ctx context.Context, req *graphql.Request, resp interface{}) error {
-	if c.token != "" {
-		req.Header.Add("Authorization", "bearer "+c.token)
-	}
-	return c.client.Run(ctx, req, resp)
-}
+	sigc := make(chan os.Signal, 2)
+	signal.Notify(sigc, os.Interrupt, syscall.SIGTERM)
 
-var redactRe = regexp.MustCompile(`Authorization:\[([^\]]+)\]\s+`)
+	go func() {
+		<-sigc
+		cancel()
+	}()
 
-func debugLog(s string) {
-	if strings.HasPrefix(s, ">> headers:") {
-		if m := redactRe.FindStringIndex(s); m != nil {
-			s = s[:m[0]] + "Authorization:[xxxxx] " + s[m[1]:]
-		}
+	if err := run(ctx, os.Args[1:]); err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
 	}
-	log.Println(s)
 }
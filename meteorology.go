@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// The models of the "feels like" temperature.
+const (
+	feelsLikeProvider  = "provider"   // reported by the provider, computed as apparent, if not reported
+	feelsLikeApparent  = "apparent"   // wind chill in the cold wind, heat index in the heat
+	feelsLikeHeatIndex = "heat_index" // heat index
+	feelsLikeWindChill = "wind_chill" // wind chill
+	feelsLikeHumidex   = "humidex"    // humidex
+)
+
+// HeatIndex returns the heat index, °C, of the temperature t, °C, and the relative humidity rh, %, using
+// the regression of Rothfusz with the adjustments of the US National Weather Service.
+// See https://www.wpc.ncep.noaa.gov/html/heatindex_equation.shtml
+func HeatIndex(t, rh float64) float64 {
+	f := fahrenheit(t)
+	hi := 0.5 * (f + 61 + (f-68)*1.2 + rh*0.094)
+	if (hi+f)/2 < 80 {
+		return celsius(hi)
+	}
+
+	hi = -42.379 + 2.04901523*f + 10.14333127*rh - 0.22475541*f*rh - 0.00683783*f*f - 0.05481717*rh*rh +
+		0.00122874*f*f*rh + 0.00085282*f*rh*rh - 0.00000199*f*f*rh*rh
+	if rh < 13 && f >= 80 && f <= 112 {
+		hi -= (13 - rh) / 4 * math.Sqrt((17-math.Abs(f-95))/17)
+	} else if rh > 85 && f >= 80 && f <= 87 {
+		hi += (rh - 85) / 10 * (87 - f) / 5
+	}
+	return celsius(hi)
+}
+
+// WindChill returns the wind chill, °C, of the temperature t, °C, and the wind speed v, km/h, using the formula
+// of the US National Weather Service and Environment Canada. The wind chill is defined for the temperatures up
+// to 10 °C and the wind speeds from 4.8 km/h, otherwise the temperature is returned.
+func WindChill(t, v float64) float64 {
+	if t > 10 || v < 4.8 {
+		return t
+	}
+	return 13.12 + 0.6215*t - 11.37*math.Pow(v, 0.16) + 0.3965*t*math.Pow(v, 0.16)
+}
+
+// DewPoint returns the dew point, °C, of the temperature t, °C, and the relative humidity rh, %, using the Magnus
+// formula with the coefficients of Alduchov and Eskridge. It returns NaN, if the humidity isn't positive.
+func DewPoint(t, rh float64) float64 {
+	const b, c = 17.625, 243.04
+	if rh <= 0 {
+		return math.NaN()
+	}
+	gamma := math.Log(rh/100) + b*t/(c+t)
+	return c * gamma / (b - gamma)
+}
+
+// Humidex returns the humidex of Environment Canada of the temperature t, °C, and the dew point td, °C.
+func Humidex(t, td float64) float64 {
+	e := 6.11 * math.Exp(5417.7530*(1/273.16-1/(273.15+td)))
+	return t + 0.5555*(e-10)
+}
+
+// ApparentTemperature returns the wind chill in the cold wind, the heat index in the heat, from 26.7 °C (80 °F),
+// and the temperature t, °C, otherwise. The relative humidity rh is in %, and the wind speed v in km/h. Without
+// the humidity, rh <= 0, the heat index isn't known, and the temperature is returned in the heat too.
+func ApparentTemperature(t, rh, v float64) float64 {
+	if t <= 10 {
+		return WindChill(t, v)
+	}
+	if t >= 26.7 && rh > 0 {
+		return HeatIndex(t, rh)
+	}
+	return t
+}
+
+func fahrenheit(c float64) float64 {
+	return c*9/5 + 32
+}
+
+func celsius(f float64) float64 {
+	return (f - 32) * 5 / 9
+}
+
+// toCelsius converts the temperature in OpenWeather's units to °C. Empty units are metric.
+func toCelsius(t float64, units string) float64 {
+	switch units {
+	case "", "metric":
+		return t
+	case "imperial":
+		return celsius(t)
+	}
+	return t - 273.15
+}
+
+// fromCelsius converts the temperature, °C, to OpenWeather's units.
+func fromCelsius(c float64, units string) float64 {
+	switch units {
+	case "", "metric":
+		return c
+	case "imperial":
+		return fahrenheit(c)
+	}
+	return c + 273.15
+}
+
+// FeelsLike returns the "feels like" temperature of the model, in the response's units. The models, that need
+// the humidity, return the temperature, if the humidity isn't reported.
+func (wr WeatherResponse) FeelsLike(model string) float64 {
+	t := toCelsius(wr.Main.Temp, wr.Units)
+	rh := wr.Main.Humidity
+	v := wr.metersPerSecond(wr.Wind.Speed) * 3.6
+
+	if rh <= 0 && (model == feelsLikeHeatIndex || model == feelsLikeHumidex) {
+		return wr.Main.Temp
+	}
+
+	var c float64
+	switch model {
+	case "", feelsLikeProvider:
+		if wr.Main.FeelsLike != nil {
+			return *wr.Main.FeelsLike
+		}
+		c = ApparentTemperature(t, rh, v)
+	case feelsLikeApparent:
+		c = ApparentTemperature(t, rh, v)
+	case feelsLikeHeatIndex:
+		c = HeatIndex(t, rh)
+	case feelsLikeWindChill:
+		c = WindChill(t, v)
+	case feelsLikeHumidex:
+		c = Humidex(t, DewPoint(t, rh))
+	default:
+		return wr.Main.Temp
+	}
+	return fromCelsius(c, wr.Units)
+}
+
+// DewPoint returns the dew point in the response's units, or NaN, if the humidity isn't reported.
+func (wr WeatherResponse) DewPoint() float64 {
+	return fromCelsius(DewPoint(toCelsius(wr.Main.Temp, wr.Units), wr.Main.Humidity), wr.Units)
+}
+
+func validateFeelsLikeModel(model string) error {
+	switch model {
+	case "", feelsLikeProvider, feelsLikeApparent, feelsLikeHeatIndex, feelsLikeWindChill, feelsLikeHumidex:
+		return nil
+	}
+	return fmt.Errorf("unknown feels like model %q", model)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestHeatIndex(t *testing.T) {
+	// The heat index chart of the US National Weather Service, °F.
+	// See https://www.weather.gov/safety/heat-index
+	cases := []struct {
+		temp float64
+		rh   float64
+		want float64
+	}{
+		{80, 40, 80},
+		{90, 50, 95},
+		{96, 65, 121},
+		{100, 40, 109},
+		{86, 90, 105},
+		{104, 10, 98},
+	}
+
+	for n, tc := range cases {
+		t.Run(fmt.Sprintf("case=%d", n), func(t *testing.T) {
+			if got := fahrenheit(HeatIndex(celsius(tc.temp), tc.rh)); math.Abs(got-tc.want) > 1 {
+				t.Errorf("HeatIndex(%v°F, %v%%): want %v°F, got %.1f°F", tc.temp, tc.rh, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestWindChill(t *testing.T) {
+	// The wind chill chart of Environment Canada, °C and km/h.
+	cases := []struct {
+		temp float64
+		wind float64
+		want float64
+	}{
+		{0, 10, -3},
+		{-10, 20, -18},
+		{-20, 30, -33},
+		{-30, 50, -49},
+		{5, 60, -2},
+		{15, 30, 15},
+		{-10, 3, -10},
+	}
+
+	for n, tc := range cases {
+		t.Run(fmt.Sprintf("case=%d", n), func(t *testing.T) {
+			if got := WindChill(tc.temp, tc.wind); math.Abs(got-tc.want) > 0.5 {
+				t.Errorf("WindChill(%v°C, %v km/h): want %v°C, got %.1f°C", tc.temp, tc.wind, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestDewPoint(t *testing.T) {
+	cases := []struct {
+		temp float64
+		rh   float64
+		want float64
+	}{
+		{20, 50, 9.3},
+		{30, 70, 23.9},
+		{10, 100, 10},
+		{-5, 80, -7.9},
+	}
+
+	for n, tc := range cases {
+		t.Run(fmt.Sprintf("case=%d", n), func(t *testing.T) {
+			if got := DewPoint(tc.temp, tc.rh); math.Abs(got-tc.want) > 0.1 {
+				t.Errorf("DewPoint(%v°C, %v%%): want %v°C, got %.2f°C", tc.temp, tc.rh, tc.want, got)
+			}
+		})
+	}
+
+	if got := DewPoint(20, 0); !math.IsNaN(got) {
+		t.Errorf("DewPoint: want NaN without humidity, got %v", got)
+	}
+}
+
+func TestHumidex(t *testing.T) {
+	// The humidex table of Environment Canada, by the temperature and the dew point, °C.
+	cases := []struct {
+		temp     float64
+		dewPoint float64
+		want     float64
+	}{
+		{25, 20, 33},
+		{30, 15, 34},
+		{35, 25, 47},
+		{40, 10, 41},
+	}
+
+	for n, tc := range cases {
+		t.Run(fmt.Sprintf("case=%d", n), func(t *testing.T) {
+			if got := Humidex(tc.temp, tc.dewPoint); math.Abs(got-tc.want) > 0.5 {
+				t.Errorf("Humidex(%v°C, %v°C): want %v, got %.1f", tc.temp, tc.dewPoint, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestWeatherResponse_FeelsLike(t *testing.T) {
+	cases := []struct {
+		units     string
+		temp      float64
+		feelsLike *float64
+		humidity  float64
+		wind      float64
+		model     string
+		want      string
+	}{
+		{"metric", -10, float64Ptr(-16.2), 70, 5.6, feelsLikeProvider, "Berlin, -10° (feels -16°)"},
+		{"metric", -10, nil, 70, 5.6, feelsLikeProvider, "Berlin, -10° (feels -18°)"},
+		{"metric", 2, float64Ptr(0), 70, 5.6, feelsLikeProvider, "Berlin, +2° (feels 0°)"},
+		{"metric", -10, float64Ptr(-16.2), 70, 5.6, feelsLikeWindChill, "Berlin, -10° (feels -18°)"},
+		{"metric", 32.2, nil, 50, 2, feelsLikeApparent, "Berlin, +32° (feels +35°)"},
+		{"metric", 32.2, nil, 50, 2, feelsLikeHeatIndex, "Berlin, +32° (feels +35°)"},
+		{"metric", 30, nil, 40, 2, feelsLikeHumidex, "Berlin, +30° (feels +34°)"},
+		{"metric", 30, nil, 0, 2, feelsLikeHumidex, "Berlin, +30° (feels +30°)"},
+		{"metric", 30, nil, 0, 2, feelsLikeProvider, "Berlin, +30° (feels +30°)"},
+		{"metric", 30, nil, 0, 2, feelsLikeApparent, "Berlin, +30° (feels +30°)"},
+		{"imperial", 86, nil, 0, 2, feelsLikeApparent, "Berlin, +86° (feels +86°)"},
+		{"metric", 18, nil, 50, 2, feelsLikeApparent, "Berlin, +18° (feels +18°)"},
+		{"imperial", 90, nil, 50, 2, feelsLikeHeatIndex, "Berlin, +90° (feels +95°)"},
+	}
+
+	for n, tc := range cases {
+		t.Run(fmt.Sprintf("case=%d", n), func(t *testing.T) {
+			var wr WeatherResponse
+			wr.Name = "Berlin"
+			wr.Units = tc.units
+			wr.Main.Temp = tc.temp
+			wr.Main.FeelsLike = tc.feelsLike
+			wr.Main.Humidity = tc.humidity
+			wr.Wind.Speed = tc.wind
+
+			if got := wr.shortString(statusFormat{FeelsLike: tc.model}); got != tc.want {
+				t.Errorf("WeatherResponse.shortString: want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func float64Ptr(f float64) *float64 {
+	return &f
+}
+
+func TestToCelsius(t *testing.T) {
+	cases := []struct {
+		temp  float64
+		units string
+		want  float64
+	}{
+		{9.07, "metric", 9.07},
+		{9.07, "", 9.07},
+		{80, "imperial", 26.67},
+		{282.22, "standard", 9.07},
+	}
+
+	for n, tc := range cases {
+		t.Run(fmt.Sprintf("case=%d", n), func(t *testing.T) {
+			if got := toCelsius(tc.temp, tc.units); math.Abs(got-tc.want) > 0.01 {
+				t.Errorf("toCelsius(%v, %q): want %v°C, got %.2f°C", tc.temp, tc.units, tc.want, got)
+			}
+		})
+	}
+}
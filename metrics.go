@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultMetricsJob = progName
+
+// metrics are the program's metrics, exposed in Prometheus text format by the daemon,
+// or pushed to Prometheus Pushgateway after a one-shot run.
+var metrics = NewMetrics()
+
+type Metrics struct {
+	Runs                 *metricVec
+	ProviderDuration     *histogramVec
+	ProviderRetries      *metricVec
+	ProviderRejected     *metricVec
+	CacheRequests        *metricVec
+	QuotaRemaining       *metricVec
+	QuotaExhausted       *metricVec
+	OWMResponses         *metricVec
+	GitHubDuration       *histogramVec
+	GitHubRateLimit      *metricVec
+	GitHubRateLimitReset *metricVec
+	GitHubRetries        *metricVec
+	LastSuccess          *metricVec
+	LocationTemperature  *metricVec
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		Runs: newMetricVec("github_weather_runs_total", "counter",
+			"Number of status updates, by outcome.", "outcome"),
+		ProviderDuration: newHistogramVec("github_weather_provider_request_duration_seconds",
+			"Duration of weather provider requests.", "provider"),
+		ProviderRetries: newMetricVec("github_weather_provider_retries_total", "counter",
+			"Number of retried weather provider requests.", "provider"),
+		ProviderRejected: newMetricVec("github_weather_provider_rejected_total", "counter",
+			"Number of weather observations, rejected as stale or implausible, by provider.", "provider", "reason"),
+		CacheRequests: newMetricVec("github_weather_cache_requests_total", "counter",
+			"Number of weather cache lookups, by result: hit, miss, revalidated or stale.", "result"),
+		QuotaRemaining: newMetricVec("github_weather_quota_remaining_calls", "gauge",
+			"Remaining API calls budget, by provider and period.", "provider", "period"),
+		QuotaExhausted: newMetricVec("github_weather_quota_exhausted_total", "counter",
+			"Number of API calls, denied by the exhausted budget, by provider.", "provider"),
+		OWMResponses: newMetricVec("github_weather_owm_responses_total", "counter",
+			"Number of OpenWeather API responses, by HTTP status code.", "code"),
+		GitHubDuration: newHistogramVec("github_weather_github_request_duration_seconds",
+			"Duration of GitHub GraphQL API requests, by operation.", "operation"),
+		GitHubRateLimit: newMetricVec("github_weather_github_ratelimit_remaining", "gauge",
+			"Remaining GitHub GraphQL API rate limit, as reported by the last response."),
+		GitHubRateLimitReset: newMetricVec("github_weather_github_ratelimit_reset_timestamp_seconds", "gauge",
+			"Unix time, when GitHub GraphQL API rate limit resets, as reported by the last response."),
+		GitHubRetries: newMetricVec("github_weather_github_retries_total", "counter",
+			"Number of retried GitHub GraphQL API requests, by operation.", "operation"),
+		LastSuccess: newMetricVec("github_weather_last_success_timestamp_seconds", "gauge",
+			"Unix time of the last successful status update."),
+		LocationTemperature: newMetricVec("github_weather_temperature_celsius", "gauge",
+			"Current temperature, by location.", "location"),
+	}
+}
+
+// ObserveRun records the outcome of a status update.
+func (m *Metrics) ObserveRun(err error, now time.Time) {
+	if errors.Is(err, ErrStatusKept) {
+		m.Runs.Add(1, "skipped")
+		return
+	}
+	if err != nil {
+		m.Runs.Add(1, "error")
+		return
+	}
+	m.Runs.Add(1, "success")
+	m.LastSuccess.Set(float64(now.Unix()))
+}
+
+// WriteTo writes all metrics in Prometheus text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	m.Runs.write(&buf)
+	m.ProviderDuration.write(&buf)
+	m.ProviderRetries.write(&buf)
+	m.ProviderRejected.write(&buf)
+	m.CacheRequests.write(&buf)
+	m.QuotaRemaining.write(&buf)
+	m.QuotaExhausted.write(&buf)
+	m.OWMResponses.write(&buf)
+	m.GitHubDuration.write(&buf)
+	m.GitHubRateLimit.write(&buf)
+	m.GitHubRateLimitReset.write(&buf)
+	m.GitHubRetries.write(&buf)
+	m.LastSuccess.write(&buf)
+	m.LocationTemperature.write(&buf)
+	return buf.WriteTo(w)
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// Push replaces the metrics of the job in Prometheus Pushgateway.
+func (m *Metrics) Push(ctx context.Context, client *http.Client, gatewayURL, job string) error {
+	var buf bytes.Buffer
+	m.WriteTo(&buf)
+
+	u := strings.TrimSuffix(gatewayURL, "/") + "/metrics/job/" + url.PathEscape(job)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("metrics push failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("metrics push failed: unexpected response status %s", resp.Status)
+	}
+	return nil
+}
+
+// metricVec is a counter or a gauge, partitioned by the values of its labels.
+type metricVec struct {
+	name   string
+	kind   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func newMetricVec(name, kind, help string, labels ...string) *metricVec {
+	return &metricVec{
+		name:   name,
+		kind:   kind,
+		help:   help,
+		labels: labels,
+		values: make(map[string]float64),
+	}
+}
+
+func (v *metricVec) Add(delta float64, labelValues ...string) {
+	v.mu.Lock()
+	v.values[labelString(v.labels, labelValues)] += delta
+	v.mu.Unlock()
+}
+
+func (v *metricVec) Set(value float64, labelValues ...string) {
+	v.mu.Lock()
+	v.values[labelString(v.labels, labelValues)] = value
+	v.mu.Unlock()
+}
+
+func (v *metricVec) write(w io.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if len(v.values) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.name, v.help, v.name, v.kind)
+	for _, ls := range sortedKeys(v.values) {
+		fmt.Fprintf(w, "%s%s %s\n", v.name, ls, formatFloat(v.values[ls]))
+	}
+}
+
+var defaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// histogramVec is a histogram with the default buckets, partitioned by the values of its labels.
+type histogramVec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu         sync.Mutex
+	histograms map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64 // cumulative count for every bucket
+	count  uint64
+	sum    float64
+}
+
+func newHistogramVec(name, help string, labels ...string) *histogramVec {
+	return &histogramVec{
+		name:       name,
+		help:       help,
+		labels:     labels,
+		histograms: make(map[string]*histogram),
+	}
+}
+
+func (v *histogramVec) Observe(value float64, labelValues ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	ls := labelString(v.labels, labelValues)
+	h := v.histograms[ls]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(defaultBuckets))}
+		v.histograms[ls] = h
+	}
+	for i, le := range defaultBuckets {
+		if value <= le {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+// ObserveSince records the time elapsed since start, in seconds.
+func (v *histogramVec) ObserveSince(start time.Time, labelValues ...string) {
+	v.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+func (v *histogramVec) write(w io.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if len(v.histograms) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", v.name, v.help, v.name)
+	for _, ls := range sortedKeys(v.histograms) {
+		h := v.histograms[ls]
+		for i, le := range defaultBuckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", v.name, withLabel(ls, "le", formatFloat(le)), h.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", v.name, withLabel(ls, "le", "+Inf"), h.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", v.name, ls, formatFloat(h.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", v.name, ls, h.count)
+	}
+}
+
+// labelString formats the labels as they appear in the exposition format, e.g. {code="200"}.
+func labelString(names, values []string) string {
+	if len(names) != len(values) {
+		panic(fmt.Sprintf("metrics: want %d label values, got %d", len(names), len(values)))
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	var s strings.Builder
+	s.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			s.WriteByte(',')
+		}
+		s.WriteString(name)
+		s.WriteByte('=')
+		s.WriteString(quoteLabelValue(values[i]))
+	}
+	s.WriteByte('}')
+	return s.String()
+}
+
+func withLabel(ls, name, value string) string {
+	l := name + "=" + quoteLabelValue(value)
+	if ls == "" {
+		return "{" + l + "}"
+	}
+	return ls[:len(ls)-1] + "," + l + "}"
+}
+
+// labelValueEscaper escapes the label values, as the exposition format allows: only the backslash, the double quote
+// and the line feed.
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func quoteLabelValue(value string) string {
+	return `"` + labelValueEscaper.Replace(value) + `"`
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]float64:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]*histogram:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
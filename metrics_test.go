@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMetrics_WriteTo(t *testing.T) {
+	m := NewMetrics()
+	m.ObserveRun(nil, time.Unix(1587644914, 0))
+	m.ObserveRun(errors.New("failed"), time.Unix(1587645514, 0))
+	m.OWMResponses.Add(1, "200")
+	m.LocationTemperature.Set(9.07, `Saint "Paul"`)
+	m.GitHubDuration.Observe(0.3, "ChangeUserStatus")
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# HELP github_weather_runs_total Number of status updates, by outcome.
+# TYPE github_weather_runs_total counter
+github_weather_runs_total{outcome="error"} 1
+github_weather_runs_total{outcome="success"} 1
+# HELP github_weather_owm_responses_total Number of OpenWeather API responses, by HTTP status code.
+# TYPE github_weather_owm_responses_total counter
+github_weather_owm_responses_total{code="200"} 1
+# HELP github_weather_github_request_duration_seconds Duration of GitHub GraphQL API requests, by operation.
+# TYPE github_weather_github_request_duration_seconds histogram
+github_weather_github_request_duration_seconds_bucket{operation="ChangeUserStatus",le="0.005"} 0
+github_weather_github_request_duration_seconds_bucket{operation="ChangeUserStatus",le="0.01"} 0
+github_weather_github_request_duration_seconds_bucket{operation="ChangeUserStatus",le="0.025"} 0
+github_weather_github_request_duration_seconds_bucket{operation="ChangeUserStatus",le="0.05"} 0
+github_weather_github_request_duration_seconds_bucket{operation="ChangeUserStatus",le="0.1"} 0
+github_weather_github_request_duration_seconds_bucket{operation="ChangeUserStatus",le="0.25"} 0
+github_weather_github_request_duration_seconds_bucket{operation="ChangeUserStatus",le="0.5"} 1
+github_weather_github_request_duration_seconds_bucket{operation="ChangeUserStatus",le="1"} 1
+github_weather_github_request_duration_seconds_bucket{operation="ChangeUserStatus",le="2.5"} 1
+github_weather_github_request_duration_seconds_bucket{operation="ChangeUserStatus",le="5"} 1
+github_weather_github_request_duration_seconds_bucket{operation="ChangeUserStatus",le="10"} 1
+github_weather_github_request_duration_seconds_bucket{operation="ChangeUserStatus",le="+Inf"} 1
+github_weather_github_request_duration_seconds_sum{operation="ChangeUserStatus"} 0.3
+github_weather_github_request_duration_seconds_count{operation="ChangeUserStatus"} 1
+# HELP github_weather_last_success_timestamp_seconds Unix time of the last successful status update.
+# TYPE github_weather_last_success_timestamp_seconds gauge
+github_weather_last_success_timestamp_seconds 1.587644914e+09
+# HELP github_weather_temperature_celsius Current temperature, by location.
+# TYPE github_weather_temperature_celsius gauge
+github_weather_temperature_celsius{location="Saint \"Paul\""} 9.07
+`
+	if got := buf.String(); got != want {
+		t.Errorf("Metrics.WriteTo: want\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestLabelString(t *testing.T) {
+	cases := []struct {
+		value string
+		want  string
+	}{
+		{`Berlin`, `{location="Berlin"}`},
+		{`Saint "Paul"`, `{location="Saint \"Paul\""}`},
+		{`C:\Weather`, `{location="C:\\Weather"}`},
+		{"Line\nbreak", `{location="Line\nbreak"}`},
+		{"Tab\there", "{location=\"Tab\there\"}"},
+		{"Zürich", `{location="Zürich"}`},
+	}
+
+	for n, tc := range cases {
+		t.Run(fmt.Sprintf("case=%d", n), func(t *testing.T) {
+			if got := labelString([]string{"location"}, []string{tc.value}); got != tc.want {
+				t.Errorf("labelString: want %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestMetrics_Push(t *testing.T) {
+	var gotMethod, gotPath string
+	var gotBody bytes.Buffer
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath = r.Method, r.URL.Path
+		gotBody.ReadFrom(r.Body)
+	}))
+	defer ts.Close()
+
+	m := NewMetrics()
+	m.Runs.Add(1, "success")
+
+	if err := m.Push(context.Background(), ts.Client(), ts.URL+"/", "github-weather"); err != nil {
+		t.Fatalf("Metrics.Push: unexpected error %v", err)
+	}
+	if gotMethod != http.MethodPut || gotPath != "/metrics/job/github-weather" {
+		t.Errorf("Metrics.Push: want PUT /metrics/job/github-weather, got %s %s", gotMethod, gotPath)
+	}
+	if !bytes.Contains(gotBody.Bytes(), []byte(`github_weather_runs_total{outcome="success"} 1`)) {
+		t.Errorf("Metrics.Push: unexpected body %q", gotBody.String())
+	}
+}
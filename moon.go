@@ -0,0 +1,56 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// moonPhaseEmojis are the moon's phases, from the new moon, every 45 degrees of the moon's elongation from the sun.
+var moonPhaseEmojis = []string{"🌑", "🌒", "🌓", "🌔", "🌕", "🌖", "🌗", "🌘"}
+
+// moonlessNightEmoji is the clear night, when the moon is below the horizon.
+const moonlessNightEmoji = "🌌"
+
+// moonriseAltitude is the moon's geocentric altitude, degrees, at moonrise and moonset, accounting for
+// the refraction, the moon's radius and its parallax.
+const moonriseAltitude = 0.125
+
+// moonPosition returns the moon's ecliptic longitude and latitude, degrees, using the low precision formulae
+// of the Astronomical Almanac, accurate to about 0.3 degrees.
+func moonPosition(t time.Time) (longitude, latitude float64) {
+	jc := julianCentury(t)
+	longitude = 218.32 + 481267.881*jc +
+		6.29*sinDeg(134.9+477198.85*jc) - 1.27*sinDeg(259.2-413335.38*jc) + 0.66*sinDeg(235.7+890534.23*jc) +
+		0.21*sinDeg(269.9+954397.70*jc) - 0.19*sinDeg(357.5+35999.05*jc) - 0.11*sinDeg(186.6+966404.05*jc)
+	latitude = 5.13*sinDeg(93.3+483202.03*jc) + 0.28*sinDeg(228.2+960400.87*jc) -
+		0.28*sinDeg(318.3+6003.18*jc) - 0.17*sinDeg(217.6-407332.20*jc)
+	return math.Mod(longitude, 360), latitude
+}
+
+// MoonElongation returns the moon's ecliptic longitude from the sun's, degrees in [0, 360): 0 is the new moon,
+// 90 the first quarter, 180 the full moon and 270 the last quarter.
+func MoonElongation(t time.Time) float64 {
+	moon, _ := moonPosition(t)
+	_, _, sun := solarPosition(t)
+	return math.Mod(math.Mod(moon-sun, 360)+360, 360)
+}
+
+// MoonPhaseEmoji returns the emoji of the moon's phase at the time, as seen from the northern hemisphere.
+func MoonPhaseEmoji(t time.Time) string {
+	phase := int(math.Round(MoonElongation(t)/45)) % len(moonPhaseEmojis)
+	return moonPhaseEmojis[phase]
+}
+
+// MoonAltitude returns the moon's geocentric altitude above the horizon, degrees, at the time and the location.
+func MoonAltitude(t time.Time, lat, lon float64) float64 {
+	longitude, latitude := moonPosition(t)
+	jd := julianDay(t)
+	obliquity := 23.439 - 0.0000004*(jd-2451545)
+
+	ra := degrees(math.Atan2(sinDeg(longitude)*cosDeg(obliquity)-tanDeg(latitude)*sinDeg(obliquity), cosDeg(longitude)))
+	declination := degrees(math.Asin(sinDeg(latitude)*cosDeg(obliquity) + cosDeg(latitude)*sinDeg(obliquity)*sinDeg(longitude)))
+
+	siderealTime := 280.46061837 + 360.98564736629*(jd-2451545) + lon
+	hourAngle := siderealTime - ra
+	return degrees(math.Asin(sinDeg(lat)*sinDeg(declination) + cosDeg(lat)*cosDeg(declination)*cosDeg(hourAngle)))
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMoonPhaseEmoji(t *testing.T) {
+	// The moon's phases, published by the US Naval Observatory, and the days between them.
+	cases := []struct {
+		at   string
+		want string
+	}{
+		{"2000-01-06T18:14:00Z", "🌑"},
+		{"2020-04-08T02:35:00Z", "🌕"},
+		{"2020-04-14T22:56:00Z", "🌗"},
+		{"2020-04-23T02:26:00Z", "🌑"},
+		{"2020-04-26T12:00:00Z", "🌒"},
+		{"2020-04-30T20:38:00Z", "🌓"},
+		{"2020-05-04T12:00:00Z", "🌔"},
+		{"2020-05-11T12:00:00Z", "🌖"},
+		{"2020-05-19T12:00:00Z", "🌘"},
+		{"2024-01-11T11:57:00Z", "🌑"},
+		{"2024-01-25T17:54:00Z", "🌕"},
+	}
+
+	for n, tc := range cases {
+		t.Run(fmt.Sprintf("case=%d", n), func(t *testing.T) {
+			at, err := time.Parse(time.RFC3339, tc.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := MoonPhaseEmoji(at); got != tc.want {
+				t.Errorf("MoonPhaseEmoji(%s): want %q, got %q (elongation %.1f)", tc.at, tc.want, got, MoonElongation(at))
+			}
+		})
+	}
+}
+
+func TestWeatherResponse_At_moon(t *testing.T) {
+	// On 2020-04-09 in Berlin, the sun set at about 17:55 UTC, and the waning moon rose at about 20:00 UTC.
+	cases := []struct {
+		at       time.Time
+		moonrise bool
+		want     string
+	}{
+		{time.Date(2020, 4, 8, 22, 0, 0, 0, time.UTC), false, "🌕"},
+		{time.Date(2020, 4, 8, 22, 0, 0, 0, time.UTC), true, "🌕"},
+		{time.Date(2020, 4, 9, 19, 0, 0, 0, time.UTC), false, "🌖"},
+		{time.Date(2020, 4, 9, 19, 0, 0, 0, time.UTC), true, "🌌"},
+		{time.Date(2020, 4, 9, 21, 0, 0, 0, time.UTC), true, "🌖"},
+		{time.Date(2020, 4, 23, 22, 0, 0, 0, time.UTC), true, "🌌"},
+	}
+
+	for n, tc := range cases {
+		t.Run(fmt.Sprintf("case=%d", n), func(t *testing.T) {
+			var wr WeatherResponse
+			wr.Coord.Lat = 52.52
+			wr.Coord.Lon = 13.41
+			wr.Timezone = 2 * 60 * 60
+			wr.Weather = append(wr.Weather, struct {
+				ID          int    `json:"id"`
+				Main        string `json:"main"`
+				Description string `json:"description"`
+				Icon        string `json:"icon"`
+			}{ID: 800, Icon: "01n"})
+
+			if got := wr.At(tc.at).emoji(statusFormat{Moonrise: tc.moonrise}); got != tc.want {
+				t.Errorf("WeatherResponse.At(%v).emoji: want %q, got %q", tc.at, tc.want, got)
+			}
+		})
+	}
+}
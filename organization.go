@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// organizationsFile caches the node IDs of the organizations, by their API host and login.
+const organizationsFile = "organizations.json"
+
+// resolveOrganization returns the node ID of the configured organization, or empty string if the status is public.
+// The IDs never change, so they are looked up once and cached in the state directory.
+func resolveOrganization(ctx context.Context, cfg Config, gh *GitHubClient) (string, error) {
+	login := cfg.GitHub.Organization
+	if login == "" {
+		return "", nil
+	}
+
+	// Logins are case-insensitive, and the same login may belong to different organizations on different GitHub hosts.
+	key := strings.ToLower(login)
+	if u, err := url.Parse(cfg.GitHub.Endpoint); err == nil {
+		key = u.Host + "/" + key
+	}
+
+	ids := make(map[string]string)
+	if err := readStateFile(cfg.StateDir, organizationsFile, &ids); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.WarnContext(ctx, "failed to read organizations cache", "error", err)
+	}
+	if id, ok := ids[key]; ok {
+		return id, nil
+	}
+
+	id, err := gh.OrganizationID(ctx, login)
+	if err != nil {
+		return "", err
+	}
+	slog.InfoContext(ctx, "resolved gh organization", "organization", login, "id", id)
+
+	ids[key] = id
+	if err := writeStateFile(cfg.StateDir, organizationsFile, ids); err != nil {
+		slog.WarnContext(ctx, "failed to save organizations cache", "error", err)
+	}
+	return id, nil
+}
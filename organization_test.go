@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolveOrganization(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"data":{"organization":{"id":"O_kgDOABCDEF"}}}`))
+	}))
+	defer ts.Close()
+
+	var cfg Config
+	cfg.StateDir = t.TempDir()
+	cfg.GitHub.Endpoint = ts.URL
+	cfg.GitHub.Organization = "My-Org"
+
+	gh := NewGitHubClient(ts.URL, "token", nil)
+	for n := 0; n < 2; n++ {
+		id, err := resolveOrganization(context.Background(), cfg, gh)
+		if err != nil {
+			t.Fatalf("resolveOrganization: unexpected error %v", err)
+		}
+		if want := "O_kgDOABCDEF"; id != want {
+			t.Errorf("resolveOrganization: want %q, got %q", want, id)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("resolveOrganization: want 1 request, got %d", requests)
+	}
+
+	cfg.GitHub.Organization = ""
+	if id, err := resolveOrganization(context.Background(), cfg, gh); err != nil || id != "" {
+		t.Errorf("resolveOrganization: no organization, want empty id, got %q, %v", id, err)
+	}
+}
+
+func TestGitHubClient_OrganizationID_notFound(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{"organization":null}}`))
+	}))
+	defer ts.Close()
+
+	gh := NewGitHubClient(ts.URL, "token", nil)
+	if _, err := gh.OrganizationID(context.Background(), "no-such-org"); err == nil {
+		t.Errorf("GitHubClient.OrganizationID: want error, got nil")
+	}
+}
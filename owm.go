@@ -0,0 +1,610 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type OWMClient struct {
+	apiURL string
+	// cacheKey is the endpoint without the API key, that the cached responses are kept by.
+	cacheKey string
+	units    string
+	client   *http.Client
+	backoff  backoff
+	// cache, if set, keeps the responses, shared by the invocations of the program.
+	cache *WeatherCache
+	// quota, if set, limits the API calls. When it's exhausted, the stale cached weather is used.
+	quota *Quota
+	// validator, if set, rejects the stale and implausible observations, using the cached weather instead, if any.
+	validator *WeatherValidator
+}
+
+// NewOWMClient creates the client of OpenWeather API, sending the requests with the HTTP client.
+// Nil HTTP client is the default client, limited with the default timeout.
+func NewOWMClient(apiURL, apiKey string, httpClient *http.Client) *OWMClient {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: defaultHTTPTimeout}
+	}
+	return &OWMClient{
+		apiURL:   strings.Replace(apiURL, "{api-key}", apiKey, 1),
+		cacheKey: owmCacheKey(apiURL),
+		units:    owmUnits(apiURL),
+		client: observeClient(httpClient, func(resp *http.Response) {
+			serverClock.Observe(resp.Header, time.Now())
+		}),
+		backoff: defaultOWMBackoff,
+	}
+}
+
+type WeatherResponse struct {
+	Cod  int    `json:"cod"`
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// Coord is the location of the city.
+	Coord struct {
+		Lon float64 `json:"lon"`
+		Lat float64 `json:"lat"`
+	} `json:"coord"`
+	// Base is the source of the observation, e.g. "stations".
+	Base string `json:"base"`
+	// Dt is the Unix time of the observation.
+	Dt int64 `json:"dt"`
+	// Timezone is the location's offset from UTC in seconds.
+	Timezone int `json:"timezone"`
+	Weather  []struct {
+		ID          int    `json:"id"`
+		Main        string `json:"main"`
+		Description string `json:"description"`
+		Icon        string `json:"icon"`
+	} `json:"weather"`
+	Main struct {
+		Temp float64 `json:"temp"`
+		// FeelsLike is the "feels like" temperature, nil, if the provider doesn't report it.
+		FeelsLike *float64 `json:"feels_like"`
+		// Pressure is the atmospheric pressure at the sea level, hPa.
+		Pressure float64 `json:"pressure"`
+		// Humidity is the relative humidity, %.
+		Humidity float64 `json:"humidity"`
+	} `json:"main"`
+	// Visibility is the visibility in meters, up to 10 km.
+	Visibility int `json:"visibility"`
+	// Wind is the wind at the location. The speeds are in meters per second, or miles per hour for imperial units.
+	Wind struct {
+		Speed float64 `json:"speed"`
+		// Deg is the direction the wind blows from, meteorological degrees.
+		Deg  float64 `json:"deg"`
+		Gust float64 `json:"gust"`
+	} `json:"wind"`
+	Clouds struct {
+		// All is the cloudiness, %.
+		All int `json:"all"`
+	} `json:"clouds"`
+	Rain Precipitation `json:"rain"`
+	Snow Precipitation `json:"snow"`
+	// Sys describes the station, that made the observation, and the day at the location.
+	Sys struct {
+		Type    int    `json:"type"`
+		ID      int    `json:"id"`
+		Country string `json:"country"`
+		Sunrise int64  `json:"sunrise"`
+		Sunset  int64  `json:"sunset"`
+	} `json:"sys"`
+	// Units are the units of the response, set by the client: "metric", "imperial" or "standard".
+	// Empty units are metric.
+	Units string `json:"units,omitempty"`
+	// Stale marks the last good weather, used when the provider fails.
+	Stale bool `json:"stale,omitempty"`
+	// FetchedAt is the time the response was received from the provider, set by the client. The cached weather
+	// was fetched before the request.
+	FetchedAt time.Time `json:"-"`
+	// Daylight is the state of the daylight at the time of the status, set by At: "day", "night" or "twilight".
+	Daylight string `json:"daylight,omitempty"`
+	// SunEvent is "sunrise" or "sunset", if the time of the status is close to them, set by At.
+	SunEvent string `json:"sun_event,omitempty"`
+	// Moon is the emoji of the moon's phase at the time of the status, set by At.
+	Moon string `json:"moon,omitempty"`
+	// MoonBelowHorizon reports, that the moon hasn't risen at the location at the time of the status, set by At.
+	MoonBelowHorizon bool `json:"moon_below_horizon,omitempty"`
+}
+
+// Precipitation is the volume of rain or snow, mm, for the last hour and the last 3 hours.
+type Precipitation struct {
+	OneHour    float64 `json:"1h"`
+	ThreeHours float64 `json:"3h"`
+}
+
+// Rate returns the precipitation rate, mm per hour, or zero, if there is no precipitation or it's not reported.
+func (p Precipitation) Rate() float64 {
+	if p.OneHour > 0 {
+		return p.OneHour
+	}
+	return p.ThreeHours / 3
+}
+
+// WeatherFromFile reads a weather response, previously saved from OpenWeather API.
+func WeatherFromFile(path string) (WeatherResponse, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return WeatherResponse{}, fmt.Errorf("error opening observation file %q: %v", path, err)
+	}
+	defer f.Close()
+
+	var wr WeatherResponse
+	if err := json.NewDecoder(f).Decode(&wr); err != nil {
+		return wr, fmt.Errorf("error parsing observation file %q: %v", path, err)
+	}
+	return wr, nil
+}
+
+// ObservedAt returns the time of the observation, or zero time if the provider didn't report it.
+func (wr WeatherResponse) ObservedAt() time.Time {
+	if wr.Dt == 0 {
+		return time.Time{}
+	}
+	return time.Unix(wr.Dt, 0)
+}
+
+// Location returns the time zone of the location, as the fixed offset from UTC, reported by the provider.
+func (wr WeatherResponse) Location() *time.Location {
+	return time.FixedZone(wr.Name, wr.Timezone)
+}
+
+// At returns a copy of the response, with the daylight, the sun's event, the moon and the day or night variant
+// of the weather icons chosen for the time t. The daylight is computed from the sun's position at the location's
+// coordinates. Without them, the time of the day is compared to the response's sunrise and sunset, so t doesn't need
+// to be on the same day.
+func (wr WeatherResponse) At(t time.Time) WeatherResponse {
+	wr.Moon = MoonPhaseEmoji(t)
+	if wr.Coord.Lat != 0 || wr.Coord.Lon != 0 {
+		wr.Daylight = daylightAt(t, wr.Coord.Lat, wr.Coord.Lon)
+		wr.SunEvent = sunEventAt(t, wr.Coord.Lat, wr.Coord.Lon, wr.Location())
+		wr.MoonBelowHorizon = MoonAltitude(t, wr.Coord.Lat, wr.Coord.Lon) < moonriseAltitude
+	} else if wr.Sys.Sunrise != 0 && wr.Sys.Sunset != 0 {
+		const day = 24 * 60 * 60
+		sinceSunrise := func(ts int64) int64 {
+			return ((ts-wr.Sys.Sunrise)%day + day) % day
+		}
+		wr.Daylight = daylightNight
+		if sinceSunrise(t.Unix()) < sinceSunrise(wr.Sys.Sunset) {
+			wr.Daylight = daylightDay
+		}
+	} else {
+		return wr
+	}
+
+	suffix := "d"
+	if wr.night() {
+		suffix = "n"
+	}
+	wr.Weather = append(wr.Weather[:0:0], wr.Weather...)
+	for i, w := range wr.Weather {
+		if w.Icon != "" {
+			wr.Weather[i].Icon = strings.TrimRight(w.Icon, "dn") + suffix
+		}
+	}
+	return wr
+}
+
+// night reports whether it's dark at the location: by the daylight, if known, or by the weather icon.
+func (wr WeatherResponse) night() bool {
+	switch wr.Daylight {
+	case daylightNight, daylightTwilight:
+		return true
+	case "":
+		return len(wr.Weather) > 0 && strings.HasSuffix(wr.Weather[0].Icon, "n")
+	}
+	return false
+}
+
+func (wr WeatherResponse) ShortString() string {
+	return wr.shortString(statusFormat{})
+}
+
+// shortString is ShortString with the optional parts of the format: the marker of the stale weather before
+// the temperature, e.g. "Berlin, ~+9°", the "feels like" temperature and the wind segments after it,
+// e.g. "Berlin, +9° (feels +6°) ↗ 5 Bft".
+func (wr WeatherResponse) shortString(f statusFormat) string {
+	var s strings.Builder
+
+	s.WriteString(wr.Name)
+	s.WriteByte(',')
+	s.WriteByte(' ')
+	if wr.Stale {
+		s.WriteString(f.StaleMarker)
+	}
+
+	writeTemperature(&s, wr.Main.Temp)
+
+	if f.FeelsLike != "" {
+		s.WriteString(" (feels ")
+		writeTemperature(&s, wr.FeelsLike(f.FeelsLike))
+		s.WriteByte(')')
+	}
+
+	for _, segment := range wr.windSegments(f) {
+		s.WriteByte(' ')
+		s.WriteString(segment)
+	}
+
+	return s.String()
+}
+
+func writeTemperature(s *strings.Builder, t float64) {
+	if t > 0 {
+		s.WriteByte('+')
+	}
+	s.WriteString(strconv.FormatFloat(t, 'f', 0, 64))
+	s.WriteString("°") // WriteString as "degree" is not from ASCII
+}
+
+// moonEmoji returns the emoji of the clear night: the moon's phase, or the starry sky, if the moon is below
+// the horizon and the format checks it.
+func (wr WeatherResponse) moonEmoji(f statusFormat) string {
+	if f.Moonrise && wr.MoonBelowHorizon {
+		return moonlessNightEmoji
+	}
+	if wr.Moon != "" {
+		return wr.Moon
+	}
+	return ":full_moon:"
+}
+
+// The rain rates, mm per hour, that separate light, moderate and heavy rain.
+const (
+	lightRainRate = 2.5
+	heavyRainRate = 7.6
+)
+
+// Emoji maps OpenWeather weather status to emojis. The wind of a gale, or stronger, takes over from the weather.
+// See https://openweathermap.org/weather-conditions
+func (wr WeatherResponse) Emoji() string {
+	return wr.emoji(statusFormat{})
+}
+
+// emoji is Emoji with the windy level of the format.
+func (wr WeatherResponse) emoji(f statusFormat) string {
+	if len(wr.Weather) == 0 {
+		return ":zap:"
+	}
+	if wr.windy(f) {
+		return ":dash:"
+	}
+
+	w := wr.Weather[0]
+	night := wr.night()
+	if w.ID <= 801 && f.SunriseSunset {
+		switch wr.SunEvent {
+		case sunEventSunrise:
+			return "🌅"
+		case sunEventSunset:
+			return "🌇"
+		}
+	}
+	if w.ID == 800 {
+		if night {
+			return wr.moonEmoji(f)
+		}
+		return ":sunny:"
+	}
+	if w.ID > 800 {
+		switch {
+		case night:
+			return ":cloud:"
+		case w.ID == 801:
+			return "🌤️"
+		case w.ID == 802:
+			return ":cloud:"
+		default:
+			return ":partly_sunny:"
+		}
+	} else if w.ID >= 700 {
+		return ":foggy:"
+	} else if w.ID >= 600 {
+		return ":snowflake:"
+	} else if w.ID >= 500 {
+		if w.ID >= 511 {
+			return "🌨️"
+		}
+		// The reported volume tells drizzle from downpour better, than the condition.
+		rate := wr.Rain.Rate()
+		if rate >= heavyRainRate {
+			return "🌧️"
+		}
+		if (rate > 0 && rate < lightRainRate) || (rate == 0 && w.ID == 500) {
+			if night {
+				return "🌧️"
+			}
+			return "🌦️"
+		}
+		return "☔"
+	} else if w.ID >= 300 {
+		if night {
+			return "🌧️"
+		}
+		return "🌦️"
+	} else if w.ID >= 200 {
+		return "⛈️"
+	}
+
+	return ":zap:"
+}
+
+var (
+	// ErrUnauthorized means the API key is invalid, or isn't activated yet.
+	ErrUnauthorized = errors.New("invalid API key")
+	// ErrLocationNotFound means the provider doesn't know the location of the query.
+	ErrLocationNotFound = errors.New("location not found")
+)
+
+// OWMError is an error response of OpenWeather API. It matches ErrUnauthorized, ErrLocationNotFound
+// or ErrRateLimited, depending on the status code.
+type OWMError struct {
+	StatusCode int
+	// Message is the message of the response, e.g. "city not found".
+	Message string
+}
+
+func (e *OWMError) Error() string {
+	s := fmt.Sprintf("weather API responded %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Message != "" {
+		s += ": " + e.Message
+	}
+	return s
+}
+
+func (e *OWMError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrLocationNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// Temporary reports whether the request may succeed, if retried.
+func (e *OWMError) Temporary() bool {
+	return e.StatusCode >= 500
+}
+
+// defaultOWMBackoff retries the failed requests for up to a few seconds.
+var defaultOWMBackoff = backoff{Attempts: 3, Base: 500 * time.Millisecond, Max: 10 * time.Second}
+
+// Weather returns the current weather for the query. The server errors and the network errors are retried.
+func (c *OWMClient) Weather(ctx context.Context, query string) (_ WeatherResponse, err error) {
+	ctx, span := startSpan(ctx, "OWMClient.Weather")
+	span.SetAttr("provider", "owm")
+	span.SetAttr("location", query)
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+
+	var wr WeatherResponse
+	for attempt := 0; ; attempt++ {
+		wr, err = c.weather(ctx, query)
+		if err == nil || !retryableOWMError(err) || attempt+1 >= c.backoff.Attempts || ctx.Err() != nil {
+			break
+		}
+		wait := c.backoff.delay(attempt)
+		slog.WarnContext(ctx, "retrying weather request", "provider", "owm", "attempt", attempt+1, "wait", wait, "error", err)
+		metrics.ProviderRetries.Add(1, "owm")
+		if serr := sleep(ctx, wait); serr != nil {
+			break
+		}
+	}
+	if err != nil {
+		return WeatherResponse{}, fmt.Errorf("weather API request failed, query %q: %w", query, err)
+	}
+
+	if len(wr.Weather) > 0 {
+		span.SetAttr("weather.condition", wr.Weather[0].ID)
+	}
+
+	return wr, nil
+}
+
+func (c *OWMClient) weather(ctx context.Context, query string) (WeatherResponse, error) {
+	var entry *CacheEntry
+	if c.cache != nil {
+		var err error
+		if entry, err = c.cache.Get("owm", c.cacheKey, query); err != nil {
+			slog.WarnContext(ctx, "failed to read weather cache", "error", err)
+		}
+		if entry != nil && entry.Fresh(time.Now()) {
+			if wr, err := c.decodeEntry(ctx, entry); err == nil {
+				// No request is made, the clock mustn't be forgotten, e.g. in a one-shot run.
+				entry.ObserveClock(serverClock)
+				metrics.CacheRequests.Add(1, "hit")
+				slog.DebugContext(ctx, "using cached weather", "location", query, "fetched_at", entry.FetchedAt)
+				return wr, nil
+			}
+			entry = nil
+		}
+	}
+
+	if c.quota != nil {
+		if err := c.quota.Take(time.Now()); errors.Is(err, ErrQuotaExhausted) {
+			if entry == nil {
+				return WeatherResponse{}, err
+			}
+			entry.ObserveClock(serverClock)
+			metrics.CacheRequests.Add(1, "stale")
+			slog.WarnContext(ctx, "using stale cached weather", "location", query, "fetched_at", entry.FetchedAt, "reason", err)
+			return c.decodeEntry(ctx, entry)
+		} else if err != nil {
+			// The broken quota state mustn't stop the updates.
+			slog.WarnContext(ctx, "failed to update API calls quota", "error", err)
+		}
+	}
+
+	resp, err := c.get(ctx, query, entry)
+	if err != nil {
+		return WeatherResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		metrics.CacheRequests.Add(1, "revalidated")
+		now := time.Now()
+		if err := c.cache.Revalidate(entry, resp.Header, now); err != nil {
+			slog.WarnContext(ctx, "failed to update weather cache", "error", err)
+		}
+		wr, err := c.decode(ctx, entry.Body)
+		if err != nil {
+			return WeatherResponse{}, err
+		}
+		wr.FetchedAt = now
+		return wr, nil
+	}
+
+	// The error responses differ from the weather, e.g. their "cod" is a string.
+	if resp.StatusCode != http.StatusOK {
+		var body struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&body)
+		return WeatherResponse{}, &OWMError{StatusCode: resp.StatusCode, Message: body.Message}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return WeatherResponse{}, fmt.Errorf("error reading weather response: %w", err)
+	}
+	fetchedAt := time.Now()
+	wr, err := c.decode(ctx, body)
+	if errors.Is(err, ErrStaleObservation) || errors.Is(err, ErrImplausibleWeather) {
+		// The rejected weather isn't cached, the older one may still be good.
+		if entry != nil {
+			if cached, cerr := c.decodeEntry(ctx, entry); cerr == nil {
+				metrics.CacheRequests.Add(1, "stale")
+				slog.WarnContext(ctx, "using stale cached weather", "location", query, "fetched_at", entry.FetchedAt, "reason", err)
+				return cached, nil
+			}
+		}
+		return WeatherResponse{}, err
+	} else if err != nil {
+		return WeatherResponse{}, err
+	}
+
+	wr.FetchedAt = fetchedAt
+
+	if c.cache != nil {
+		metrics.CacheRequests.Add(1, "miss")
+		if err := c.cache.Put("owm", c.cacheKey, query, resp.Header, body, fetchedAt); err != nil {
+			slog.WarnContext(ctx, "failed to update weather cache", "error", err)
+		}
+	}
+	return wr, nil
+}
+
+func decodeWeather(data []byte) (WeatherResponse, error) {
+	var wr WeatherResponse
+	if err := json.Unmarshal(data, &wr); err != nil {
+		return WeatherResponse{}, fmt.Errorf("error parsing weather response: %v", err)
+	}
+	if wr.Cod != 0 && wr.Cod != http.StatusOK {
+		return WeatherResponse{}, fmt.Errorf("weather API bad response: %+v", wr)
+	}
+	return wr, nil
+}
+
+// decode decodes the weather, rejecting it, if it's stale or implausible.
+// decodeEntry decodes the cached weather, keeping the time it was fetched.
+func (c *OWMClient) decodeEntry(ctx context.Context, entry *CacheEntry) (WeatherResponse, error) {
+	wr, err := c.decode(ctx, entry.Body)
+	if err != nil {
+		return WeatherResponse{}, err
+	}
+	wr.FetchedAt = entry.FetchedAt
+	return wr, nil
+}
+
+func (c *OWMClient) decode(ctx context.Context, data []byte) (WeatherResponse, error) {
+	wr, err := decodeWeather(data)
+	if err != nil {
+		return wr, err
+	}
+	wr.Units = c.units
+	if c.validator == nil {
+		return wr, nil
+	}
+	if err := c.validator.Validate(wr, serverClock.Now()); err != nil {
+		reason := "implausible"
+		if errors.Is(err, ErrStaleObservation) {
+			reason = "stale"
+		}
+		metrics.ProviderRejected.Add(1, "owm", reason)
+		slog.WarnContext(ctx, "rejected weather", "provider", "owm", "location", wr.Name, "observed_at", wr.ObservedAt(), "reason", err)
+		return WeatherResponse{}, err
+	}
+	return wr, nil
+}
+
+// owmCacheKey returns the endpoint without the API key, so the responses in the other units or language are cached
+// apart, and the key isn't stored in the cache.
+func owmCacheKey(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return endpoint
+	}
+	q := u.Query()
+	q.Del("appid")
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func retryableOWMError(err error) bool {
+	var oerr *OWMError
+	if errors.As(err, &oerr) {
+		return oerr.Temporary()
+	}
+	return retryableNetError(err)
+}
+
+// get requests the current weather for the query. If the cached entry is given, the request is conditional.
+// The caller must close the response body.
+func (c *OWMClient) get(ctx context.Context, query string, cached *CacheEntry) (*http.Response, error) {
+	u := c.apiURL + "&q=" + url.QueryEscape(query)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	start := time.Now()
+	resp, err := c.client.Do(req)
+	metrics.ProviderDuration.ObserveSince(start, "owm")
+	if err != nil {
+		// The request URL includes the API key, that must not leak through the error message.
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			uerr.URL = redactor.Redact(uerr.URL)
+		}
+		return nil, err
+	}
+
+	metrics.OWMResponses.Add(1, strconv.Itoa(resp.StatusCode))
+
+	return resp, nil
+}
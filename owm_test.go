@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWeatherResponse_ShortString(t *testing.T) {
+	cases := []struct {
+		temp float64
+		want string
+	}{
+		{9.07, "Berlin, +9°"},
+		{12.94, "Berlin, +13°"},
+		{-5.55, "Berlin, -6°"},
+	}
+
+	for n, tc := range cases {
+		t.Run(fmt.Sprintf("case=%d", n), func(t *testing.T) {
+			var wr WeatherResponse
+			wr.Name = "Berlin"
+			wr.Main.Temp = tc.temp
+			if got := wr.ShortString(); got != tc.want {
+				t.Errorf("WeatherResponse.ShortString: want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestWeatherResponse_Emoji_rain(t *testing.T) {
+	cases := []struct {
+		condition int
+		rain      Precipitation
+		want      string
+	}{
+		{500, Precipitation{}, "🌦️"},
+		{501, Precipitation{}, "☔"},
+		{501, Precipitation{OneHour: 0.8}, "🌦️"},
+		{500, Precipitation{OneHour: 4}, "☔"},
+		{500, Precipitation{ThreeHours: 27}, "🌧️"},
+		{502, Precipitation{OneHour: 12.5}, "🌧️"},
+		{511, Precipitation{OneHour: 12.5}, "🌨️"},
+	}
+
+	for n, tc := range cases {
+		t.Run(fmt.Sprintf("case=%d", n), func(t *testing.T) {
+			var wr WeatherResponse
+			wr.Weather = append(wr.Weather, struct {
+				ID          int    `json:"id"`
+				Main        string `json:"main"`
+				Description string `json:"description"`
+				Icon        string `json:"icon"`
+			}{ID: tc.condition, Main: "Rain", Icon: "10d"})
+			wr.Rain = tc.rain
+			if got := wr.Emoji(); got != tc.want {
+				t.Errorf("WeatherResponse.Emoji: want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestDecodeWeather(t *testing.T) {
+	// https://openweathermap.org/current#example_JSON
+	const data = `{"coord":{"lon":13.41,"lat":52.52},"weather":[{"id":501,"main":"Rain","description":"moderate rain","icon":"10d"}],
+"base":"stations","main":{"temp":9.07,"feels_like":6.24,"pressure":1021,"humidity":71},"visibility":10000,
+"wind":{"speed":4.1,"deg":250,"gust":8.7},"rain":{"1h":3.16},"clouds":{"all":90},"dt":1587646800,
+"sys":{"type":1,"id":1275,"country":"DE","sunrise":1587614340,"sunset":1587666240},"timezone":7200,"id":2950159,"name":"Berlin","cod":200}`
+
+	wr, err := decodeWeather([]byte(data))
+	if err != nil {
+		t.Fatalf("decodeWeather: unexpected error %v", err)
+	}
+
+	got := fmt.Sprintf("%v %v %d %v/%v/%v %d %v %v %d %s %d",
+		wr.Main.Humidity, wr.Main.Pressure, wr.Visibility, wr.Wind.Speed, wr.Wind.Deg, wr.Wind.Gust, wr.Clouds.All,
+		wr.Rain.Rate(), wr.Snow.Rate(), wr.Sys.ID, wr.Sys.Country, wr.Timezone)
+	if want := "71 1021 10000 4.1/250/8.7 90 3.16 0 1275 DE 7200"; got != want {
+		t.Errorf("decodeWeather: want %q, got %q", want, got)
+	}
+	if want := time.Date(2020, 4, 23, 13, 0, 0, 0, time.UTC); !wr.ObservedAt().Equal(want) {
+		t.Errorf("WeatherResponse.ObservedAt: want %v, got %v", want, wr.ObservedAt())
+	}
+	if got, want := wr.ObservedAt().In(wr.Location()).Hour(), 15; got != want {
+		t.Errorf("WeatherResponse.Location: want local hour %d, got %d", want, got)
+	}
+}
+
+func TestWeatherResponse_At(t *testing.T) {
+	var wr WeatherResponse
+	wr.Weather = append(wr.Weather, struct {
+		ID          int    `json:"id"`
+		Main        string `json:"main"`
+		Description string `json:"description"`
+		Icon        string `json:"icon"`
+	}{ID: 800, Main: "Clear", Icon: "01d"})
+	// 2020-04-23 in Berlin: sunrise at 03:59 UTC, sunset at 18:24 UTC.
+	wr.Sys.Sunrise = 1587614340
+	wr.Sys.Sunset = 1587666240
+
+	cases := []struct {
+		at   time.Time
+		want string
+	}{
+		{time.Date(2020, 4, 23, 12, 0, 0, 0, time.UTC), ":sunny:"},
+		{time.Date(2020, 4, 23, 22, 0, 0, 0, time.UTC), "🌑"},
+		{time.Date(2020, 4, 23, 2, 0, 0, 0, time.UTC), "🌑"},
+		{time.Date(2020, 6, 1, 22, 0, 0, 0, time.UTC), "🌔"},
+		{time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC), ":sunny:"},
+	}
+
+	for n, tc := range cases {
+		t.Run(fmt.Sprintf("case=%d", n), func(t *testing.T) {
+			if got := wr.At(tc.at).Emoji(); got != tc.want {
+				t.Errorf("WeatherResponse.At(%v).Emoji: want %q, got %q", tc.at, tc.want, got)
+			}
+		})
+	}
+
+	if wr.Weather[0].Icon != "01d" {
+		t.Errorf("WeatherResponse.At: modified the original response, icon %q", wr.Weather[0].Icon)
+	}
+}
+
+func TestOWMClient_Weather_errors(t *testing.T) {
+	const weather = `{"cod":200,"name":"Berlin","weather":[{"id":800,"icon":"01d"}],"main":{"temp":9.07}}`
+
+	cases := []struct {
+		name         string
+		codes        []int
+		body         string
+		wantRequests int
+		wantErr      error
+		wantMessage  string
+	}{
+		{"unauthorized", []int{401}, `{"cod":401,"message":"Invalid API key. Please see https://openweathermap.org/faq#error401 for more info."}`, 1, ErrUnauthorized, "Invalid API key"},
+		{"not found", []int{404}, `{"cod":"404","message":"city not found"}`, 1, ErrLocationNotFound, "city not found"},
+		{"rate limited", []int{429}, `{"cod":429,"message":"Your account is temporary blocked due to exceeding of requests limitation of your subscription type."}`, 1, ErrRateLimited, "temporary blocked"},
+		{"server error", []int{500}, `{"cod":"500","message":"Internal error"}`, 3, nil, "Internal error"},
+		{"retried", []int{503, 200}, weather, 2, nil, ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests int
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				code := tc.codes[len(tc.codes)-1]
+				if requests < len(tc.codes) {
+					code = tc.codes[requests]
+				}
+				requests++
+				w.WriteHeader(code)
+				if code == http.StatusOK {
+					w.Write([]byte(weather))
+					return
+				}
+				w.Write([]byte(tc.body))
+			}))
+			defer ts.Close()
+
+			owm := NewOWMClient(ts.URL+"/weather?appid={api-key}", "key", nil)
+			owm.backoff = backoff{Attempts: 3, Base: time.Millisecond, Max: time.Second}
+
+			wr, err := owm.Weather(context.Background(), "Berlin")
+			if tc.wantMessage == "" {
+				if err != nil {
+					t.Fatalf("OWMClient.Weather: unexpected error %v", err)
+				}
+				if want := "Berlin"; wr.Name != want {
+					t.Errorf("OWMClient.Weather: want %q, got %q", want, wr.Name)
+				}
+			} else {
+				if err == nil || !strings.Contains(err.Error(), tc.wantMessage) {
+					t.Errorf("OWMClient.Weather: want error with %q, got %v", tc.wantMessage, err)
+				}
+				if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+					t.Errorf("OWMClient.Weather: want error %v, got %v", tc.wantErr, err)
+				}
+			}
+			if requests != tc.wantRequests {
+				t.Errorf("OWMClient.Weather: want %d requests, got %d", tc.wantRequests, requests)
+			}
+		})
+	}
+}
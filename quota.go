@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// The budgets of OpenWeather's free plan, see https://openweathermap.org/price
+	defaultOWMCallsPerMinute = 60
+	defaultOWMCallsPerDay    = 1000
+)
+
+// ErrQuotaExhausted means the API call would exceed the configured budget.
+var ErrQuotaExhausted = errors.New("API calls budget exhausted")
+
+// Quota limits the API calls with a per-minute and a daily budget, using token buckets: each bucket holds up to
+// the budget of calls, and refills continuously over its period. The buckets are persisted in the state directory,
+// so all invocations of the program and all locations, that share the API key, share the budgets.
+type Quota struct {
+	provider  string
+	dir       string
+	perMinute int
+	perDay    int
+
+	mu sync.Mutex
+}
+
+// QuotaUsage is the state of the quota's buckets.
+type QuotaUsage struct {
+	PerMinute int
+	PerDay    int
+	// MinuteRemaining and DayRemaining are the numbers of calls, the buckets hold.
+	MinuteRemaining float64
+	DayRemaining    float64
+}
+
+type quotaState struct {
+	Minute tokenBucket `json:"minute"`
+	Day    tokenBucket `json:"day"`
+}
+
+type tokenBucket struct {
+	Tokens    float64   `json:"tokens"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// refill adds the tokens, accumulated since the bucket's last update. A new bucket is full.
+func (b *tokenBucket) refill(capacity int, period time.Duration, now time.Time) {
+	if b.UpdatedAt.IsZero() {
+		b.Tokens = float64(capacity)
+	} else if elapsed := now.Sub(b.UpdatedAt); elapsed > 0 {
+		b.Tokens = math.Min(float64(capacity), b.Tokens+elapsed.Seconds()*float64(capacity)/period.Seconds())
+	}
+	b.UpdatedAt = now
+}
+
+// wait returns the time until the bucket holds a token.
+func (b *tokenBucket) wait(capacity int, period time.Duration) time.Duration {
+	if b.Tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.Tokens) / float64(capacity) * float64(period))
+}
+
+func NewQuota(provider, dir string, perMinute, perDay int) *Quota {
+	return &Quota{
+		provider:  provider,
+		dir:       dir,
+		perMinute: perMinute,
+		perDay:    perDay,
+	}
+}
+
+// Take spends a call from both budgets. If either of them is exhausted, it returns ErrQuotaExhausted,
+// telling when the next call is possible. The state file is locked, while the call is taken, so the concurrent
+// invocations of the program don't spend the same calls.
+func (q *Quota) Take(now time.Time) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	unlock, err := lockStateFile(q.dir, q.file())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	st, err := q.load(now)
+	if err != nil {
+		return err
+	}
+
+	if st.Minute.Tokens < 1 || st.Day.Tokens < 1 {
+		wait := st.Minute.wait(q.perMinute, time.Minute)
+		if w := st.Day.wait(q.perDay, 24*time.Hour); w > wait {
+			wait = w
+		}
+		metrics.QuotaExhausted.Add(1, q.provider)
+		return fmt.Errorf("%w, next call in %s", ErrQuotaExhausted, wait.Round(time.Second))
+	}
+
+	st.Minute.Tokens--
+	st.Day.Tokens--
+	q.observe(st)
+	return writeStateFile(q.dir, q.file(), st)
+}
+
+// Usage returns the state of the budgets, without spending anything.
+func (q *Quota) Usage(now time.Time) (QuotaUsage, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	st, err := q.load(now)
+	if err != nil {
+		return QuotaUsage{}, err
+	}
+	return QuotaUsage{
+		PerMinute:       q.perMinute,
+		PerDay:          q.perDay,
+		MinuteRemaining: st.Minute.Tokens,
+		DayRemaining:    st.Day.Tokens,
+	}, nil
+}
+
+func (q *Quota) file() string {
+	return q.provider + "-quota.json"
+}
+
+func (q *Quota) load(now time.Time) (quotaState, error) {
+	var st quotaState
+	if err := readStateFile(q.dir, q.file(), &st); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return st, err
+	}
+	st.Minute.refill(q.perMinute, time.Minute, now)
+	st.Day.refill(q.perDay, 24*time.Hour, now)
+	return st, nil
+}
+
+func (q *Quota) observe(st quotaState) {
+	metrics.QuotaRemaining.Set(math.Floor(st.Minute.Tokens), q.provider, "minute")
+	metrics.QuotaRemaining.Set(math.Floor(st.Day.Tokens), q.provider, "day")
+}
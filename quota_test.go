@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQuota_Take(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2020, 4, 23, 12, 0, 0, 0, time.UTC)
+
+	q := NewQuota("owm", dir, 2, 3)
+	for i := 0; i < 2; i++ {
+		if err := q.Take(now); err != nil {
+			t.Fatalf("Quota.Take: unexpected error %v", err)
+		}
+	}
+	if err := q.Take(now); !errors.Is(err, ErrQuotaExhausted) {
+		t.Fatalf("Quota.Take: want ErrQuotaExhausted, got %v", err)
+	}
+
+	// The budget is shared with other invocations through the state directory.
+	q = NewQuota("owm", dir, 2, 3)
+	now = now.Add(30 * time.Second)
+	if err := q.Take(now); err != nil {
+		t.Fatalf("Quota.Take: want minute budget refilled, got %v", err)
+	}
+	now = now.Add(time.Minute)
+	err := q.Take(now)
+	if !errors.Is(err, ErrQuotaExhausted) {
+		t.Fatalf("Quota.Take: want daily budget exhausted, got %v", err)
+	}
+	if want := "API calls budget exhausted, next call in 7h58m30s"; err.Error() != want {
+		t.Errorf("Quota.Take: want error %q, got %q", want, err)
+	}
+
+	u, err := q.Usage(now)
+	if err != nil {
+		t.Fatalf("Quota.Usage: %v", err)
+	}
+	if u.MinuteRemaining != 2 || u.DayRemaining >= 1 {
+		t.Errorf("Quota.Usage: want 2 calls per minute and no calls per day remaining, got %+v", u)
+	}
+}
+
+func TestQuota_Take_concurrent(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2020, 4, 23, 12, 0, 0, 0, time.UTC)
+
+	// The invocations of the program, running at the same time, share the budget, and don't spend the same calls.
+	quotas := []*Quota{NewQuota("owm", dir, 20, 1000), NewQuota("owm", dir, 20, 1000)}
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		taken int
+	)
+	for i := 0; i < 50; i++ {
+		for _, q := range quotas {
+			wg.Add(1)
+			go func(q *Quota) {
+				defer wg.Done()
+				err := q.Take(now)
+				if err != nil && !errors.Is(err, ErrQuotaExhausted) {
+					t.Errorf("Quota.Take: unexpected error %v", err)
+				}
+				if err == nil {
+					mu.Lock()
+					taken++
+					mu.Unlock()
+				}
+			}(q)
+		}
+	}
+	wg.Wait()
+
+	if taken != 20 {
+		t.Errorf("Quota.Take: want 20 calls taken, got %d", taken)
+	}
+}
+
+func TestOWMClient_Weather_quota(t *testing.T) {
+	const weather = `{"cod":200,"name":"Berlin","weather":[{"id":800,"icon":"01d"}],"main":{"temp":9.07}}`
+
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(weather))
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	owm := NewOWMClient(ts.URL+"/weather?appid={api-key}", "key", nil)
+	owm.quota = NewQuota("owm", dir, 1, 100)
+
+	if _, err := owm.Weather(context.Background(), "Berlin,DE"); err != nil {
+		t.Fatalf("OWMClient.Weather: unexpected error %v", err)
+	}
+	if _, err := owm.Weather(context.Background(), "Berlin,DE"); !errors.Is(err, ErrQuotaExhausted) {
+		t.Fatalf("OWMClient.Weather: want ErrQuotaExhausted without cache, got %v", err)
+	}
+
+	// The expired cache entry is better, than no weather at all.
+	owm.cache = NewWeatherCache(dir, 0)
+	if err := owm.cache.Put("owm", owm.cacheKey, "Berlin,DE", http.Header{}, []byte(weather), time.Now()); err != nil {
+		t.Fatalf("WeatherCache.Put: %v", err)
+	}
+	wr, err := owm.Weather(context.Background(), "Berlin,DE")
+	if err != nil {
+		t.Fatalf("OWMClient.Weather: want stale cached weather, got %v", err)
+	}
+	if want := "Berlin"; wr.Name != want {
+		t.Errorf("OWMClient.Weather: want %q, got %q", want, wr.Name)
+	}
+	if requests != 1 {
+		t.Errorf("OWMClient.Weather: want 1 request, got %d", requests)
+	}
+}
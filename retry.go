@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"time"
+)
+
+// ErrRateLimited is matched by the errors of the requests, rejected by the APIs' rate limits, e.g. GitHub's primary
+// or secondary rate limits, or OpenWeather's calls per minute.
+var ErrRateLimited = errors.New("API rate limit exceeded")
+
+// backoff is an exponential backoff with full jitter: the wait before a retry is random, up to the base,
+// doubled for every failed attempt, so the retries of many clients don't happen all at once.
+type backoff struct {
+	// Attempts is the maximum number of attempts, including the first one.
+	Attempts int
+	Base     time.Duration
+	// Max limits the wait before a retry, including the waits, requested by the servers.
+	Max time.Duration
+}
+
+// delay returns the wait before the retry, that follows the failed attempt, counting from 0.
+func (b backoff) delay(attempt int) time.Duration {
+	d := b.Base << uint(attempt)
+	if d <= 0 || d > b.Max {
+		d = b.Max
+	}
+	return time.Duration(rand.Int63n(int64(d))) + 1
+}
+
+// sleep waits for the duration, unless the context is done. It fails immediately, if the context's deadline
+// comes before the wait is over, because the retry would fail anyway.
+func sleep(ctx context.Context, d time.Duration) error {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		return fmt.Errorf("retry in %s is past the deadline", d.Round(time.Millisecond))
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// retryableNetError reports whether the request failed with a network error, that may go away on retry.
+// Certificate errors don't go away.
+func retryableNetError(err error) bool {
+	var (
+		urlErr           *url.Error
+		unknownAuthority x509.UnknownAuthorityError
+		hostnameErr      x509.HostnameError
+		invalidErr       x509.CertificateInvalidError
+	)
+	if !errors.As(err, &urlErr) {
+		return false
+	}
+	return !errors.As(err, &unknownAuthority) && !errors.As(err, &hostnameErr) && !errors.As(err, &invalidErr)
+}
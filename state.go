@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const lastStatusFile = "last-status.json"
+
+func defaultStateDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, progName)
+}
+
+// readStateFile decodes the named JSON file from the state directory. It returns os.ErrNotExist if the file doesn't exist.
+func readStateFile(dir, name string, v interface{}) error {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("error parsing state file %q: %v", name, err)
+	}
+	return nil
+}
+
+// writeStateFile encodes v into the named JSON file in the state directory. The file is replaced atomically,
+// so concurrent invocations of the program never see it partially written.
+func writeStateFile(dir, name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, name), data, 0600)
+}
+
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("error creating state directory: %w", err)
+	}
+
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// lockStateFile locks the named file in the state directory against the other invocations of the program, waiting
+// until they unlock it, so they can read, modify and write it in turn. The lock is a separate file, that is never
+// removed, next to the named file.
+func lockStateFile(dir, name string) (unlock func() error, err error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("error creating state directory: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(dir, name+".lock"), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error locking state file %q: %v", name, err)
+	}
+	return func() error {
+		unlockFile(f)
+		return f.Close()
+	}, nil
+}
+
+func removeStateFile(dir, name string) error {
+	err := os.Remove(filepath.Join(dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,15 @@
+//go:build !unix && !windows
+
+package main
+
+import "os"
+
+// lockFile doesn't lock anything on the platforms without file locks, leaving the invocations of the program
+// to run concurrently.
+func lockFile(f *os.File) error {
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
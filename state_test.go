@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestStateFile(t *testing.T) {
+	dir := t.TempDir()
+
+	var got ChangeUserStatusInput
+	if err := readStateFile(dir, lastStatusFile, &got); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("readStateFile: want os.ErrNotExist, got %v", err)
+	}
+
+	want := ChangeUserStatusInput{
+		ClientMutationID: "github/weather",
+		Emoji:            ":sunny:",
+		Message:          "Berlin, +9°",
+		ExpiresAt:        time.Date(2020, 4, 23, 12, 58, 34, 0, time.UTC),
+	}
+	if err := writeStateFile(dir, lastStatusFile, want); err != nil {
+		t.Fatalf("writeStateFile: %v", err)
+	}
+	if err := readStateFile(dir, lastStatusFile, &got); err != nil {
+		t.Fatalf("readStateFile: %v", err)
+	}
+	if got != want {
+		t.Errorf("readStateFile: want %+v, got %+v", want, got)
+	}
+
+	if err := removeStateFile(dir, lastStatusFile); err != nil {
+		t.Fatalf("removeStateFile: %v", err)
+	}
+	if err := removeStateFile(dir, lastStatusFile); err != nil {
+		t.Errorf("removeStateFile: missing file, unexpected error %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+var errNotOwnStatus = errors.New("current status was not set by " + progName)
+
+// statusFormat configures the optional parts of the status. The zero format is the default.
+type statusFormat struct {
+	// StaleMarker is written before the temperature of the stale weather.
+	StaleMarker string
+	// FeelsLike is the model of the "feels like" temperature, written after the temperature, if set.
+	FeelsLike string
+	// WindSegments are the wind segments, written after the temperature.
+	WindSegments []string
+	// WindyBeaufort is the wind force, from which the windy emoji replaces the weather's.
+	WindyBeaufort int
+	// GustBeaufort is the gusts force, from which the gust segment is written.
+	GustBeaufort int
+	// SunriseSunset shows the sunrise and sunset emojis around them, when the sky is clear.
+	SunriseSunset bool
+	// Moonrise shows the starry sky instead of the moon, when the moon is below the horizon.
+	Moonrise bool
+}
+
+func newStatusFormat(cfg Config) statusFormat {
+	return statusFormat{
+		StaleMarker:   cfg.Degraded.Marker,
+		FeelsLike:     cfg.FeelsLike,
+		WindSegments:  cfg.Wind.Segments,
+		WindyBeaufort: cfg.Wind.WindyBeaufort,
+		GustBeaufort:  cfg.Wind.GustBeaufort,
+		SunriseSunset: cfg.SunriseSunset,
+		Moonrise:      cfg.Moonrise,
+	}
+}
+
+func (f statusFormat) windyBeaufort() int {
+	if f.WindyBeaufort == 0 {
+		return defaultWindyBeaufort
+	}
+	return f.WindyBeaufort
+}
+
+func (f statusFormat) gustBeaufort() int {
+	if f.GustBeaufort == 0 {
+		return defaultGustBeaufort
+	}
+	return f.GustBeaufort
+}
+
+// newStatus renders the user status for the weather response, expiring relative to now. The day or night
+// variant of the emoji is chosen for now.
+func newStatus(cfg Config, wr WeatherResponse, now time.Time) ChangeUserStatusInput {
+	f := newStatusFormat(cfg)
+	wr = wr.At(now)
+	return ChangeUserStatusInput{
+		ClientMutationID: cfg.GitHub.ClientID,
+		Emoji:            wr.emoji(f),
+		Message:          wr.shortString(f),
+		ExpiresAt:        now.UTC().Add(time.Duration(cfg.ExpirationTime) * time.Minute),
+	}
+}
+
+// StatusUpdate is the result of a successful status update.
+type StatusUpdate struct {
+	Observation WeatherResponse       `json:"observation"`
+	Status      ChangeUserStatusInput `json:"status"`
+}
+
+// updateStatus fetches the current weather and sets user's status to it.
+// When the provider fails, the degraded policy decides, whether the last good weather is used instead.
+func updateStatus(ctx context.Context, cfg Config, owm *OWMClient, gh *GitHubClient) (update StatusUpdate, err error) {
+	ctx, span := startSpan(ctx, "update status")
+	span.SetAttr("location", cfg.OWM.Query)
+	defer func() {
+		if err == nil && update.Observation.Stale {
+			metrics.Runs.Add(1, "stale")
+		} else {
+			metrics.ObserveRun(err, time.Now())
+		}
+		span.SetError(err)
+		span.End()
+	}()
+
+	wr, err := owm.Weather(ctx, cfg.OWM.Query)
+	if err != nil {
+		if wr, err = degradedWeather(ctx, cfg, err, time.Now()); err != nil {
+			return StatusUpdate{}, err
+		}
+	} else if err := saveObservation(cfg, wr, wr.FetchedAt); err != nil {
+		slog.WarnContext(ctx, "failed to save last weather", "error", err)
+	}
+
+	logWeather(ctx, wr)
+	metrics.LocationTemperature.Set(toCelsius(wr.Main.Temp, wr.Units), wr.Name)
+
+	status := newStatus(cfg, wr, serverClock.Now())
+	if status.OrganizationID, err = resolveOrganization(ctx, cfg, gh); err != nil {
+		return StatusUpdate{}, err
+	}
+	sr, err := gh.ChangeUserStatus(ctx, status)
+	if err != nil {
+		return StatusUpdate{}, err
+	}
+
+	slog.InfoContext(ctx, "set gh status", "emoji", status.Emoji, "message", status.Message, "updated_at", sr.UpdatedAt, "expires_at", sr.ExpiresAt)
+
+	return StatusUpdate{Observation: wr, Status: status}, nil
+}
+
+func logWeather(ctx context.Context, wr WeatherResponse) {
+	var condition int
+	if len(wr.Weather) > 0 {
+		condition = wr.Weather[0].ID
+	}
+	slog.InfoContext(ctx, "got owm response", "location", wr.Name, "condition", condition, "temp", wr.Main.Temp, "feels_like", wr.FeelsLike(feelsLikeProvider), "humidity", wr.Main.Humidity, "wind_beaufort", wr.WindBeaufort(), "observed_at", wr.ObservedAt(), "station", wr.Sys.ID)
+}
+
+// clearStatus clears user's status, if it is still the last status, set by the program.
+// If last is nil, the status is cleared unconditionally.
+func clearStatus(ctx context.Context, cfg Config, gh *GitHubClient, last *ChangeUserStatusInput) error {
+	us, err := gh.UserStatus(ctx)
+	if err != nil {
+		return err
+	}
+	if us == nil {
+		slog.InfoContext(ctx, "no gh status to clear")
+		return nil
+	}
+	if last != nil && !isOwnStatus(us, *last) {
+		return errNotOwnStatus
+	}
+
+	if err := gh.ClearUserStatus(ctx, cfg.GitHub.ClientID); err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "cleared gh status", "emoji", us.Emoji, "message", us.Message)
+
+	return nil
+}
+
+// isOwnStatus reports whether the user's status is the same, as the last status set by the program.
+func isOwnStatus(us *UserStatus, last ChangeUserStatusInput) bool {
+	return us.Emoji == last.Emoji && us.Message == last.Message
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// The sun's elevations, degrees, at sunrise and sunset, accounting for the refraction and the sun's radius,
+// and at the end of the civil twilight.
+const (
+	sunriseElevation = -0.833
+	civilElevation   = -6
+)
+
+// sunEventWindow is the time around sunrise and sunset, the status shows them.
+const sunEventWindow = 30 * time.Minute
+
+// The states of the daylight.
+const (
+	daylightDay      = "day"
+	daylightNight    = "night"
+	daylightTwilight = "twilight"
+)
+
+// The sun's events, the status shows around them.
+const (
+	sunEventSunrise = "sunrise"
+	sunEventSunset  = "sunset"
+)
+
+// SunTimes are the times of the sun's events on a day. The events, that don't happen on the day, e.g. the sunset
+// during the polar day, are zero.
+type SunTimes struct {
+	Dawn    time.Time // the beginning of the civil twilight
+	Sunrise time.Time
+	Noon    time.Time
+	Sunset  time.Time
+	Dusk    time.Time // the end of the civil twilight
+}
+
+// solarPosition is the sun's declination, degrees, the equation of time, minutes, and the sun's apparent ecliptic
+// longitude, degrees, using the calculations of the US National Oceanic and Atmospheric Administration.
+// See https://gml.noaa.gov/grad/solcalc/calcdetails.html
+func solarPosition(t time.Time) (declination, eqTime, longitude float64) {
+	jc := julianCentury(t)
+
+	meanLong := math.Mod(280.46646+jc*(36000.76983+jc*0.0003032), 360)
+	meanAnomaly := 357.52911 + jc*(35999.05029-0.0001537*jc)
+	eccentricity := 0.016708634 - jc*(0.000042037+0.0000001267*jc)
+	center := sinDeg(meanAnomaly)*(1.914602-jc*(0.004817+0.000014*jc)) + sinDeg(2*meanAnomaly)*(0.019993-0.000101*jc) +
+		sinDeg(3*meanAnomaly)*0.000289
+	omega := 125.04 - 1934.136*jc
+	apparentLong := meanLong + center - 0.00569 - 0.00478*sinDeg(omega)
+	meanObliquity := 23 + (26+(21.448-jc*(46.815+jc*(0.00059-jc*0.001813)))/60)/60
+	obliquity := meanObliquity + 0.00256*cosDeg(omega)
+
+	declination = degrees(math.Asin(sinDeg(obliquity) * sinDeg(apparentLong)))
+
+	y := math.Pow(tanDeg(obliquity/2), 2)
+	eqTime = 4 * degrees(y*sinDeg(2*meanLong)-2*eccentricity*sinDeg(meanAnomaly)+
+		4*eccentricity*y*sinDeg(meanAnomaly)*cosDeg(2*meanLong)-0.5*y*y*sinDeg(4*meanLong)-
+		1.25*eccentricity*eccentricity*sinDeg(2*meanAnomaly))
+	return declination, eqTime, apparentLong
+}
+
+// julianDay returns the Julian day of the time.
+func julianDay(t time.Time) float64 {
+	return float64(t.Unix())/86400 + 2440587.5
+}
+
+// julianCentury returns the Julian centuries since J2000.0.
+func julianCentury(t time.Time) float64 {
+	return (julianDay(t) - 2451545) / 36525
+}
+
+// SolarElevation returns the sun's elevation above the horizon, degrees, at the time and the location.
+func SolarElevation(t time.Time, lat, lon float64) float64 {
+	declination, eqTime, _ := solarPosition(t)
+	u := t.UTC()
+	utcMinutes := float64(u.Hour()*60+u.Minute()) + float64(u.Second())/60
+	trueSolarTime := math.Mod(utcMinutes+eqTime+4*lon, 1440)
+	hourAngle := trueSolarTime/4 - 180
+	cosZenith := sinDeg(lat)*sinDeg(declination) + cosDeg(lat)*cosDeg(declination)*cosDeg(hourAngle)
+	return 90 - degrees(math.Acos(math.Max(-1, math.Min(1, cosZenith))))
+}
+
+// SunTimesOn returns the times of the sun's events at the location on the date, in the date's time zone.
+func SunTimesOn(date time.Time, lat, lon float64) SunTimes {
+	y, m, d := date.Date()
+	loc := date.Location()
+	// The declination and the equation of time change little during the day, so it's enough to compute them
+	// at the local noon.
+	declination, eqTime, _ := solarPosition(time.Date(y, m, d, 12, 0, 0, 0, loc))
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	noon := midnight.Add(minutes(720 - 4*lon - eqTime))
+
+	event := func(elevation, direction float64) time.Time {
+		cosHA := cosDeg(90-elevation)/(cosDeg(lat)*cosDeg(declination)) - tanDeg(lat)*tanDeg(declination)
+		if cosHA < -1 || cosHA > 1 {
+			// The sun doesn't reach the elevation on the day.
+			return time.Time{}
+		}
+		return noon.Add(minutes(direction * 4 * degrees(math.Acos(cosHA)))).In(loc)
+	}
+
+	return SunTimes{
+		Dawn:    event(civilElevation, -1),
+		Sunrise: event(sunriseElevation, -1),
+		Noon:    noon.In(loc),
+		Sunset:  event(sunriseElevation, 1),
+		Dusk:    event(civilElevation, 1),
+	}
+}
+
+// daylightAt returns the state of the daylight at the time and the location, by the sun's elevation: day, twilight
+// or night.
+func daylightAt(t time.Time, lat, lon float64) string {
+	elevation := SolarElevation(t, lat, lon)
+	switch {
+	case elevation >= sunriseElevation:
+		return daylightDay
+	case elevation >= civilElevation:
+		return daylightTwilight
+	}
+	return daylightNight
+}
+
+// sunEventAt returns sunrise or sunset, if the time is within sunEventWindow of them at the location, or "" otherwise.
+func sunEventAt(t time.Time, lat, lon float64, loc *time.Location) string {
+	st := SunTimesOn(t.In(loc), lat, lon)
+	near := func(event time.Time) bool {
+		return !event.IsZero() && t.Sub(event) > -sunEventWindow && t.Sub(event) < sunEventWindow
+	}
+	switch {
+	case near(st.Sunrise):
+		return sunEventSunrise
+	case near(st.Sunset):
+		return sunEventSunset
+	}
+	return ""
+}
+
+func minutes(m float64) time.Duration {
+	return time.Duration(m * float64(time.Minute))
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+func sinDeg(deg float64) float64 {
+	return math.Sin(radians(deg))
+}
+
+func cosDeg(deg float64) float64 {
+	return math.Cos(radians(deg))
+}
+
+func tanDeg(deg float64) float64 {
+	return math.Tan(radians(deg))
+}
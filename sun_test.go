@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSunTimesOn(t *testing.T) {
+	berlin := time.FixedZone("CEST", 2*60*60)
+	sanFrancisco := time.FixedZone("PDT", -7*60*60)
+	tromso := time.FixedZone("CET", 60*60)
+
+	// The times are rounded to minutes. Empty times don't happen on the day, and "*" only checks that they do.
+	cases := []struct {
+		date     time.Time
+		lat, lon float64
+		dawn     string
+		sunrise  string
+		sunset   string
+		dusk     string
+	}{
+		{time.Date(2020, 4, 23, 0, 0, 0, 0, berlin), 52.52, 13.41, "05:12", "05:50", "20:19", "20:57"},
+		{time.Date(2020, 6, 21, 0, 0, 0, 0, sanFrancisco), 37.77, -122.42, "05:17", "05:48", "20:35", "21:06"},
+		{time.Date(2020, 6, 21, 0, 0, 0, 0, tromso), 69.65, 18.96, "", "", "", ""},
+		{time.Date(2020, 12, 21, 0, 0, 0, 0, tromso), 69.65, 18.96, "*", "", "", "*"},
+	}
+
+	for n, tc := range cases {
+		t.Run(fmt.Sprintf("case=%d", n), func(t *testing.T) {
+			st := SunTimesOn(tc.date, tc.lat, tc.lon)
+			want := []string{tc.dawn, tc.sunrise, tc.sunset, tc.dusk}
+			var got []string
+			for i, event := range []time.Time{st.Dawn, st.Sunrise, st.Sunset, st.Dusk} {
+				switch {
+				case event.IsZero():
+					got = append(got, "")
+				case want[i] == "*":
+					got = append(got, "*")
+				default:
+					got = append(got, event.Round(time.Minute).Format("15:04"))
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("SunTimesOn(%s): want %q, got %q", tc.date.Format("2006-01-02"), want, got)
+			}
+		})
+	}
+}
+
+func TestWeatherResponse_At_daylight(t *testing.T) {
+	// In Berlin on 2020-04-23, the sun rises at 3:50 and sets at 18:19 UTC.
+	cases := []struct {
+		at            time.Time
+		condition     int
+		sunriseSunset bool
+		wantDaylight  string
+		wantSunEvent  string
+		wantIcon      string
+		wantEmoji     string
+	}{
+		{time.Date(2020, 4, 23, 12, 0, 0, 0, time.UTC), 800, false, daylightDay, "", "01d", ":sunny:"},
+		{time.Date(2020, 4, 23, 22, 0, 0, 0, time.UTC), 800, false, daylightNight, "", "01n", "🌑"},
+		{time.Date(2020, 4, 23, 3, 55, 0, 0, time.UTC), 800, false, daylightDay, sunEventSunrise, "01d", ":sunny:"},
+		{time.Date(2020, 4, 23, 3, 55, 0, 0, time.UTC), 800, true, daylightDay, sunEventSunrise, "01d", "🌅"},
+		{time.Date(2020, 4, 23, 3, 40, 0, 0, time.UTC), 800, false, daylightTwilight, sunEventSunrise, "01n", "🌑"},
+		{time.Date(2020, 4, 23, 3, 40, 0, 0, time.UTC), 800, true, daylightTwilight, sunEventSunrise, "01n", "🌅"},
+		{time.Date(2020, 4, 23, 18, 10, 0, 0, time.UTC), 801, true, daylightDay, sunEventSunset, "01d", "🌇"},
+		{time.Date(2020, 4, 23, 18, 10, 0, 0, time.UTC), 804, true, daylightDay, sunEventSunset, "01d", ":partly_sunny:"},
+		{time.Date(2020, 4, 23, 18, 45, 0, 0, time.UTC), 800, false, daylightTwilight, sunEventSunset, "01n", "🌑"},
+		{time.Date(2020, 4, 23, 18, 45, 0, 0, time.UTC), 800, true, daylightTwilight, sunEventSunset, "01n", "🌇"},
+		{time.Date(2020, 4, 23, 18, 45, 0, 0, time.UTC), 804, true, daylightTwilight, sunEventSunset, "01n", ":cloud:"},
+		{time.Date(2020, 4, 23, 18, 55, 0, 0, time.UTC), 801, true, daylightTwilight, "", "01n", ":cloud:"},
+		{time.Date(2020, 4, 23, 22, 0, 0, 0, time.UTC), 500, false, daylightNight, "", "01n", "🌧️"},
+		{time.Date(2020, 4, 23, 12, 0, 0, 0, time.UTC), 500, false, daylightDay, "", "01d", "🌦️"},
+		{time.Date(2020, 4, 23, 22, 0, 0, 0, time.UTC), 310, false, daylightNight, "", "01n", "🌧️"},
+	}
+
+	for n, tc := range cases {
+		t.Run(fmt.Sprintf("case=%d", n), func(t *testing.T) {
+			var wr WeatherResponse
+			wr.Coord.Lat = 52.52
+			wr.Coord.Lon = 13.41
+			wr.Timezone = 2 * 60 * 60
+			// The icon of the observation time doesn't matter.
+			wr.Weather = append(wr.Weather, struct {
+				ID          int    `json:"id"`
+				Main        string `json:"main"`
+				Description string `json:"description"`
+				Icon        string `json:"icon"`
+			}{ID: tc.condition, Icon: "01d"})
+
+			wr = wr.At(tc.at)
+			if wr.Daylight != tc.wantDaylight {
+				t.Errorf("WeatherResponse.At(%v): want daylight %q, got %q", tc.at, tc.wantDaylight, wr.Daylight)
+			}
+			if wr.SunEvent != tc.wantSunEvent {
+				t.Errorf("WeatherResponse.At(%v): want sun event %q, got %q", tc.at, tc.wantSunEvent, wr.SunEvent)
+			}
+			if got := wr.Weather[0].Icon; got != tc.wantIcon {
+				t.Errorf("WeatherResponse.At(%v): want icon %q, got %q", tc.at, tc.wantIcon, got)
+			}
+			if got := wr.emoji(statusFormat{SunriseSunset: tc.sunriseSunset}); got != tc.wantEmoji {
+				t.Errorf("WeatherResponse.At(%v).emoji: want %q, got %q", tc.at, tc.wantEmoji, got)
+			}
+		})
+	}
+}
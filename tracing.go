@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTracingServiceName = progName
+	// defaultTracingTimeout limits the export of a trace, when not configured.
+	defaultTracingTimeout = 5 * time.Second
+)
+
+// tracer records the spans of the program's runs. Until it's set up with an exporter, the spans aren't recorded.
+var tracer = &Tracer{}
+
+// Tracer is a minimal OpenTelemetry tracer. It collects the ended spans and exports them to an OTLP/HTTP collector,
+// when the root span of the trace ends.
+type Tracer struct {
+	endpoint    string // OTLP/HTTP traces endpoint, e.g. http://localhost:4318/v1/traces
+	serviceName string
+	client      *http.Client
+
+	mu    sync.Mutex
+	spans []*Span
+}
+
+// NewTracer creates the tracer, exporting the traces with the HTTP client. Nil HTTP client is the default client,
+// limited with the default timeout.
+func NewTracer(endpoint, serviceName string, httpClient *http.Client) *Tracer {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: defaultTracingTimeout}
+	}
+	return &Tracer{
+		endpoint:    strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		serviceName: serviceName,
+		client:      httpClient,
+	}
+}
+
+// setupTracing replaces the program's tracer, if tracing is configured.
+func setupTracing(cfg Config) error {
+	endpoint := cfg.Tracing.Endpoint
+	if endpoint == "" {
+		endpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	}
+	if endpoint == "" {
+		return nil
+	}
+	httpClient, err := newHTTPClient(cfg.Tracing.HTTP)
+	if err != nil {
+		return fmt.Errorf("error configuring tracing client: %v", err)
+	}
+	tracer = NewTracer(endpoint, cfg.Tracing.ServiceName, httpClient)
+	return nil
+}
+
+// enabled reports whether the tracer exports the traces.
+func (t *Tracer) enabled() bool {
+	return t.endpoint != ""
+}
+
+type spanKey struct{}
+
+// Span is a single operation within a trace.
+type Span struct {
+	tracer   *Tracer
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+	kind     int
+	start    time.Time
+	end      time.Time
+
+	mu    sync.Mutex
+	attrs map[string]interface{}
+	err   error
+	ended bool
+}
+
+// Span kinds, as defined by OTLP.
+const (
+	spanKindInternal = 1
+	spanKindClient   = 3
+)
+
+// Start creates a span, that is a child of the span in the context, if there is one.
+func (t *Tracer) Start(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	s := &Span{
+		tracer: t,
+		name:   name,
+		kind:   kind,
+		start:  time.Now(),
+		attrs:  make(map[string]interface{}),
+	}
+	if parent, ok := ctx.Value(spanKey{}).(*Span); ok {
+		s.traceID = parent.traceID
+		s.parentID = parent.spanID
+	} else {
+		rand.Read(s.traceID[:])
+	}
+	rand.Read(s.spanID[:])
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// startSpan starts an internal span with the program's tracer.
+func startSpan(ctx context.Context, name string) (context.Context, *Span) {
+	return tracer.Start(ctx, name, spanKindInternal)
+}
+
+// SetAttr sets span's attribute. The value must be a string, a bool, an int or a float64.
+func (s *Span) SetAttr(key string, value interface{}) {
+	s.mu.Lock()
+	s.attrs[key] = value
+	s.mu.Unlock()
+}
+
+// SetError marks the span as failed, if err isn't nil.
+func (s *Span) SetError(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
+
+// End finishes the span. When the root span ends, the trace is exported.
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+
+	t := s.tracer
+	if !t.enabled() {
+		return
+	}
+
+	t.mu.Lock()
+	t.spans = append(t.spans, s)
+	var spans []*Span
+	if s.parentID == [8]byte{} {
+		spans = t.takeTrace(s.traceID)
+	}
+	t.mu.Unlock()
+
+	if len(spans) > 0 {
+		if err := t.export(spans); err != nil {
+			slog.Warn("failed to export trace", "error", err)
+		}
+	}
+}
+
+// takeTrace removes the ended spans of the trace from the buffer.
+func (t *Tracer) takeTrace(traceID [16]byte) []*Span {
+	var trace, rest []*Span
+	for _, s := range t.spans {
+		if s.traceID == traceID {
+			trace = append(trace, s)
+		} else {
+			rest = append(rest, s)
+		}
+	}
+	t.spans = rest
+	return trace
+}
+
+// traceparent formats the span's context as a W3C Trace Context header.
+func (s *Span) traceparent() string {
+	return "00-" + hex.EncodeToString(s.traceID[:]) + "-" + hex.EncodeToString(s.spanID[:]) + "-01"
+}
+
+// export sends the spans to the collector, encoded as OTLP JSON.
+func (t *Tracer) export(spans []*Span) error {
+	type keyValue struct {
+		Key   string                 `json:"key"`
+		Value map[string]interface{} `json:"value"`
+	}
+	type otlpSpan struct {
+		TraceID           string     `json:"traceId"`
+		SpanID            string     `json:"spanId"`
+		ParentSpanID      string     `json:"parentSpanId,omitempty"`
+		Name              string     `json:"name"`
+		Kind              int        `json:"kind"`
+		StartTimeUnixNano string     `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+		Attributes        []keyValue `json:"attributes,omitempty"`
+		Status            struct {
+			Code    int    `json:"code,omitempty"`
+			Message string `json:"message,omitempty"`
+		} `json:"status"`
+	}
+
+	encoded := make([]otlpSpan, len(spans))
+	for i, s := range spans {
+		o := otlpSpan{
+			TraceID:           hex.EncodeToString(s.traceID[:]),
+			SpanID:            hex.EncodeToString(s.spanID[:]),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		}
+		if s.parentID != [8]byte{} {
+			o.ParentSpanID = hex.EncodeToString(s.parentID[:])
+		}
+		for k, v := range s.attrs {
+			o.Attributes = append(o.Attributes, keyValue{k, otlpValue(v)})
+		}
+		if s.err != nil {
+			o.Status.Code = 2 // STATUS_CODE_ERROR
+			o.Status.Message = redactor.Redact(s.err.Error())
+		}
+		encoded[i] = o
+	}
+
+	body := map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": []keyValue{{"service.name", otlpValue(t.serviceName)}},
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]string{"name": progName},
+						"spans": encoded,
+					},
+				},
+			},
+		},
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	resp, err := t.client.Post(t.endpoint, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+	return nil
+}
+
+func otlpValue(v interface{}) map[string]interface{} {
+	switch v := v.(type) {
+	case bool:
+		return map[string]interface{}{"boolValue": v}
+	case int:
+		// OTLP JSON encodes 64-bit integers as strings.
+		return map[string]interface{}{"intValue": strconv.Itoa(v)}
+	case float64:
+		return map[string]interface{}{"doubleValue": v}
+	default:
+		return map[string]interface{}{"stringValue": redactor.Redact(fmt.Sprint(v))}
+	}
+}
+
+// tracingTransport is an http.RoundTripper, that records a client span for every request, including the time
+// spent on the DNS lookup, and propagates the trace context to the server.
+type tracingTransport struct {
+	next http.RoundTripper
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := tracer.Start(req.Context(), "HTTP "+req.Method, spanKindClient)
+	defer span.End()
+
+	span.SetAttr("http.method", req.Method)
+	span.SetAttr("http.url", req.URL.String())
+	span.SetAttr("net.peer.name", req.URL.Hostname())
+
+	var dnsSpan *Span
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			_, dnsSpan = tracer.Start(ctx, "DNS lookup", spanKindClient)
+			dnsSpan.SetAttr("net.host.name", info.Host)
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			if dnsSpan != nil {
+				dnsSpan.SetError(info.Err)
+				dnsSpan.End()
+			}
+		},
+	})
+
+	req = req.Clone(ctx)
+	if span.tracer.enabled() {
+		req.Header.Set("Traceparent", span.traceparent())
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		span.SetError(err)
+		return nil, err
+	}
+	span.SetAttr("http.status_code", resp.StatusCode)
+	if resp.StatusCode >= 500 {
+		span.SetError(fmt.Errorf("response status %s", resp.Status))
+	}
+	return resp, nil
+}
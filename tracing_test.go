@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTracer_export(t *testing.T) {
+	var traceparent string
+	owmServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("Traceparent")
+		w.Write([]byte(`{"cod":200,"name":"Berlin","weather":[{"id":800,"icon":"01d"}],"main":{"temp":9.07}}`))
+	}))
+	defer owmServer.Close()
+
+	var exported struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID      string `json:"traceId"`
+					SpanID       string `json:"spanId"`
+					ParentSpanID string `json:"parentSpanId"`
+					Name         string `json:"name"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	var exportPath string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		exportPath = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&exported)
+	}))
+	defer collector.Close()
+
+	defer func(t *Tracer) { tracer = t }(tracer)
+	tracer = NewTracer(collector.URL, "github-weather", nil)
+
+	ctx, root := startSpan(context.Background(), "update status")
+	owm := NewOWMClient(owmServer.URL+"/weather?appid={api-key}", "key", nil)
+	if _, err := owm.Weather(ctx, "Berlin"); err != nil {
+		t.Fatal(err)
+	}
+	root.End()
+
+	if exportPath != "/v1/traces" {
+		t.Fatalf("Tracer: want export to /v1/traces, got %q", exportPath)
+	}
+	if len(exported.ResourceSpans) != 1 || len(exported.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("Tracer: unexpected export %+v", exported)
+	}
+
+	spans := exported.ResourceSpans[0].ScopeSpans[0].Spans
+	byName := make(map[string]int)
+	for i, s := range spans {
+		byName[s.Name] = i
+		if s.TraceID != spans[0].TraceID {
+			t.Errorf("Tracer: span %q is from a different trace", s.Name)
+		}
+	}
+	parents := map[string]string{
+		"update status":     "",
+		"OWMClient.Weather": "update status",
+		"HTTP GET":          "OWMClient.Weather",
+	}
+	for name, parent := range parents {
+		i, ok := byName[name]
+		if !ok {
+			t.Errorf("Tracer: span %q not exported, got %+v", name, spans)
+			continue
+		}
+		var wantParentID string
+		if parent != "" {
+			wantParentID = spans[byName[parent]].SpanID
+		}
+		if got := spans[i].ParentSpanID; got != wantParentID {
+			t.Errorf("Tracer: span %q, want parent %q, got %q", name, wantParentID, got)
+		}
+	}
+
+	httpSpan := spans[byName["HTTP GET"]]
+	if want := "00-" + httpSpan.TraceID + "-" + httpSpan.SpanID + "-01"; traceparent != want {
+		t.Errorf("Tracer: want traceparent %q, got %q", want, traceparent)
+	}
+}
+
+func TestTracer_disabled(t *testing.T) {
+	traceparent := "unset"
+	owmServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("Traceparent")
+		w.Write([]byte(`{"cod":200,"name":"Berlin","weather":[{"id":800,"icon":"01d"}],"main":{"temp":9.07}}`))
+	}))
+	defer owmServer.Close()
+
+	// Without tracing configured, the trace context isn't propagated.
+	defer func(t *Tracer) { tracer = t }(tracer)
+	tracer = &Tracer{}
+
+	ctx, root := startSpan(context.Background(), "update status")
+	owm := NewOWMClient(owmServer.URL+"/weather?appid={api-key}", "key", nil)
+	if _, err := owm.Weather(ctx, "Berlin"); err != nil {
+		t.Fatal(err)
+	}
+	root.End()
+
+	if traceparent != "" {
+		t.Errorf("Tracer: want no traceparent, got %q", traceparent)
+	}
+}
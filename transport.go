@@ -0,0 +1,170 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultHTTPTimeout limits the time of an API request, including reading the response, when not configured.
+	defaultHTTPTimeout = 30 * time.Second
+	// defaultConnectTimeout limits the time to establish a connection, when not configured.
+	defaultConnectTimeout = 5 * time.Second
+)
+
+// HTTPConfig configures the HTTP client of an API.
+type HTTPConfig struct {
+	// CAFile is a PEM bundle of the certificates, trusted in addition to the system's, e.g. a private CA.
+	CAFile string `yaml:"ca_file"`
+	// CertFile and KeyFile are the PEM client certificate and its key, for the servers that require mutual TLS.
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// Proxy is the URL of the proxy, overriding HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+	Proxy string `yaml:"proxy"`
+	// NoProxy is the comma-separated list of the hosts and domains, reached without the configured proxy.
+	NoProxy string `yaml:"no_proxy"`
+	// ConnectTimeout limits the time to establish a connection, and Timeout the time of the whole request.
+	ConnectTimeout time.Duration `yaml:"connect_timeout"`
+	Timeout        time.Duration `yaml:"timeout"`
+}
+
+// newHTTPClient creates the HTTP client, configured with the TLS, proxy and timeout settings.
+func newHTTPClient(hc HTTPConfig) (*http.Client, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	proxy, err := proxyFunc(hc)
+	if err != nil {
+		return nil, err
+	}
+	t.Proxy = proxy
+
+	if hc.ConnectTimeout > 0 {
+		t.DialContext = (&net.Dialer{
+			Timeout:   hc.ConnectTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+	}
+
+	if hc.CAFile != "" || hc.CertFile != "" || hc.KeyFile != "" {
+		tlsConfig, err := newTLSConfig(hc)
+		if err != nil {
+			return nil, err
+		}
+		t.TLSClientConfig = tlsConfig
+	}
+
+	return &http.Client{
+		Transport: t,
+		Timeout:   hc.Timeout,
+	}, nil
+}
+
+func newTLSConfig(hc HTTPConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+
+	if hc.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		data, err := os.ReadFile(hc.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA file: %v", err)
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in CA file %q", hc.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if hc.CertFile != "" || hc.KeyFile != "" {
+		if hc.CertFile == "" || hc.KeyFile == "" {
+			return nil, fmt.Errorf("client certificate requires both cert_file and key_file")
+		}
+		cert, err := tls.LoadX509KeyPair(hc.CertFile, hc.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// proxyFunc returns the function, that chooses the proxy for a request: the configured proxy,
+// or the one from the environment.
+func proxyFunc(hc HTTPConfig) (func(*http.Request) (*url.URL, error), error) {
+	if hc.Proxy == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	proxyURL, err := url.Parse(hc.Proxy)
+	if err != nil || proxyURL.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q", hc.Proxy)
+	}
+
+	var noProxy []string
+	for _, s := range strings.Split(hc.NoProxy, ",") {
+		if s = strings.ToLower(strings.TrimSpace(s)); s != "" {
+			noProxy = append(noProxy, strings.TrimPrefix(s, "."))
+		}
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		host := strings.ToLower(req.URL.Hostname())
+		for _, s := range noProxy {
+			if s == "*" || host == s || strings.HasSuffix(host, "."+s) {
+				return nil, nil
+			}
+		}
+		return proxyURL, nil
+	}, nil
+}
+
+// observeClient returns a copy of the HTTP client, which transport traces the requests and passes the responses
+// to the observe function. Nil client is the same as the default client.
+func observeClient(client *http.Client, observe func(*http.Response)) *http.Client {
+	var c http.Client
+	if client != nil {
+		c = *client
+	}
+	next := c.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	c.Transport = &responseObserver{
+		next:    &tracingTransport{next: next},
+		observe: observe,
+	}
+	return &c
+}
+
+// githubGraphQLURL derives the GraphQL API endpoint from GitHub's URL. The endpoint of GitHub Enterprise Server is
+// "<base URL>/api/graphql", so it's enough to configure the base URL, e.g. "https://github.example.com".
+// The URLs, that already point to a GraphQL endpoint, are returned as is.
+func githubGraphQLURL(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		// The invalid URL fails the configuration validation.
+		return endpoint
+	}
+	if strings.HasSuffix(u.Path, "/graphql") {
+		return endpoint
+	}
+	switch strings.ToLower(u.Host) {
+	case "github.com", "api.github.com":
+		return defaultGitHubAPIEndpoint
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	// REST API URL of GitHub Enterprise Server, as used by other tools.
+	path = strings.TrimSuffix(path, "/api/v3")
+	u.Path = path + "/api/graphql"
+	return u.String()
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitHubGraphQLURL(t *testing.T) {
+	cases := []struct {
+		endpoint string
+		want     string
+	}{
+		{"https://api.github.com/graphql", "https://api.github.com/graphql"},
+		{"https://github.com", "https://api.github.com/graphql"},
+		{"https://github.example.com", "https://github.example.com/api/graphql"},
+		{"https://github.example.com/", "https://github.example.com/api/graphql"},
+		{"https://github.example.com/api/v3", "https://github.example.com/api/graphql"},
+		{"https://github.example.com/api/graphql", "https://github.example.com/api/graphql"},
+		{"http://localhost:8080/graphql", "http://localhost:8080/graphql"},
+	}
+
+	for n, tc := range cases {
+		t.Run(fmt.Sprintf("case=%d", n), func(t *testing.T) {
+			if got := githubGraphQLURL(tc.endpoint); got != tc.want {
+				t.Errorf("githubGraphQLURL: want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestProxyFunc(t *testing.T) {
+	proxy, err := proxyFunc(HTTPConfig{Proxy: "http://proxy.example.com:3128", NoProxy: "localhost, .corp.example.com"})
+	if err != nil {
+		t.Fatalf("proxyFunc: unexpected error %v", err)
+	}
+
+	cases := []struct {
+		url  string
+		want string
+	}{
+		{"https://api.github.com/graphql", "http://proxy.example.com:3128"},
+		{"http://localhost:8080/graphql", ""},
+		{"https://corp.example.com/api/graphql", ""},
+		{"https://github.corp.example.com/api/graphql", ""},
+		{"https://notcorp.example.com/api/graphql", "http://proxy.example.com:3128"},
+	}
+
+	for n, tc := range cases {
+		t.Run(fmt.Sprintf("case=%d", n), func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, tc.url, nil)
+			u, err := proxy(req)
+			if err != nil {
+				t.Fatalf("proxy: unexpected error %v", err)
+			}
+			var got string
+			if u != nil {
+				got = u.String()
+			}
+			if got != tc.want {
+				t.Errorf("proxy: want %q, got %q", tc.want, got)
+			}
+		})
+	}
+
+	if _, err := proxyFunc(HTTPConfig{Proxy: "proxy.example.com"}); err == nil {
+		t.Errorf("proxyFunc: invalid proxy, want error, got nil")
+	}
+}
+
+func TestNewHTTPClient_caFile(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	if err := os.WriteFile(caFile, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := newHTTPClient(HTTPConfig{})
+	if err != nil {
+		t.Fatalf("newHTTPClient: unexpected error %v", err)
+	}
+	if _, err := client.Get(ts.URL); err == nil {
+		t.Errorf("Client.Get: without CA file, want error, got nil")
+	}
+
+	client, err = newHTTPClient(HTTPConfig{CAFile: caFile})
+	if err != nil {
+		t.Fatalf("newHTTPClient: unexpected error %v", err)
+	}
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("Client.Get: with CA file, unexpected error %v", err)
+	}
+	resp.Body.Close()
+
+	if _, err := newHTTPClient(HTTPConfig{CertFile: caFile}); err == nil {
+		t.Errorf("newHTTPClient: cert file without key file, want error, got nil")
+	}
+}
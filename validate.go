@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// defaultMaxObservationAge is how old the provider's observation can be, before it's rejected. OpenWeather's stations
+// report at least hourly, so an older observation means the station is down.
+const defaultMaxObservationAge = 3 * time.Hour
+
+var (
+	// ErrStaleObservation means the provider returned an observation, older than allowed.
+	ErrStaleObservation = errors.New("stale observation")
+	// ErrImplausibleWeather means the provider returned physically implausible values.
+	ErrImplausibleWeather = errors.New("implausible weather")
+)
+
+// The plausible ranges of the values, a bit wider than the world records.
+const (
+	minPlausibleTemp = -95 // °C
+	maxPlausibleTemp = 65  // °C
+	// The sea level pressure, hPa, a bit wider than the records of the typhoons and the anticyclones.
+	minPlausiblePressure = 850
+	maxPlausiblePressure = 1090
+	// The wind speed, m/s, a bit above the strongest gust measured, 113 m/s.
+	maxPlausibleWind = 115
+	// The visibility, m. OpenWeather caps it at 10 km, the other sources hardly see farther than 100 km.
+	maxPlausibleVisibility = 100000
+	// Time zones range from UTC-12 to UTC+14.
+	minPlausibleTimezone = -12 * 60 * 60
+	maxPlausibleTimezone = 14 * 60 * 60
+	// maxClockAhead is how far in the future the observation time can be, because of the clocks' skew.
+	maxClockAhead = 10 * time.Minute
+)
+
+// WeatherValidator rejects the observations, that are too old, or outside the physically plausible ranges.
+type WeatherValidator struct {
+	maxAge time.Duration
+	units  string
+}
+
+// NewWeatherValidator creates the validator of the observations, with temperatures in the units, that OpenWeather
+// supports: "metric", "imperial" or "standard" (Kelvin).
+func NewWeatherValidator(maxAge time.Duration, units string) *WeatherValidator {
+	return &WeatherValidator{
+		maxAge: maxAge,
+		units:  units,
+	}
+}
+
+// owmUnits returns the units of the temperatures, the OpenWeather endpoint responds with.
+func owmUnits(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "standard"
+	}
+	if units := u.Query().Get("units"); units != "" {
+		return units
+	}
+	return "standard"
+}
+
+// Validate checks the observation at the time now. The returned error matches ErrStaleObservation
+// or ErrImplausibleWeather.
+func (v *WeatherValidator) Validate(wr WeatherResponse, now time.Time) error {
+	if observed := wr.ObservedAt(); !observed.IsZero() {
+		if age := now.Sub(observed); v.maxAge > 0 && age > v.maxAge {
+			return fmt.Errorf("%w: observed %s ago, at %s", ErrStaleObservation, age.Round(time.Minute), observed.UTC().Format(time.RFC3339))
+		}
+		if observed.Sub(now) > maxClockAhead {
+			return fmt.Errorf("%w: observation time %s is in the future", ErrImplausibleWeather, observed.UTC().Format(time.RFC3339))
+		}
+	}
+
+	if err := v.checkTemperature("temperature", wr.Main.Temp); err != nil {
+		return err
+	}
+	if wr.Main.FeelsLike != nil {
+		if err := v.checkTemperature("feels like temperature", *wr.Main.FeelsLike); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range []struct {
+		name     string
+		value    float64
+		min, max float64
+		// optional values are zero, when not reported.
+		optional bool
+	}{
+		{"humidity", wr.Main.Humidity, 0, 100, false},
+		{"pressure", wr.Main.Pressure, minPlausiblePressure, maxPlausiblePressure, true},
+		{"wind speed", v.metersPerSecond(wr.Wind.Speed), 0, maxPlausibleWind, false},
+		{"wind gust", v.metersPerSecond(wr.Wind.Gust), 0, maxPlausibleWind, false},
+		{"visibility", float64(wr.Visibility), 0, maxPlausibleVisibility, false},
+	} {
+		if r.optional && r.value == 0 {
+			continue
+		}
+		if r.value < r.min || r.value > r.max {
+			return fmt.Errorf("%w: %s %g is out of range", ErrImplausibleWeather, r.name, r.value)
+		}
+	}
+
+	if wr.Timezone < minPlausibleTimezone || wr.Timezone > maxPlausibleTimezone {
+		return fmt.Errorf("%w: time zone offset %ds is out of range", ErrImplausibleWeather, wr.Timezone)
+	}
+	return nil
+}
+
+func (v *WeatherValidator) checkTemperature(name string, t float64) error {
+	if c := toCelsius(t, v.units); c < minPlausibleTemp || c > maxPlausibleTemp {
+		return fmt.Errorf("%w: %s %.1f°C is out of range", ErrImplausibleWeather, name, c)
+	}
+	return nil
+}
+
+// metersPerSecond converts the wind speed in the validator's units to m/s.
+func (v *WeatherValidator) metersPerSecond(speed float64) float64 {
+	if v.units == "imperial" {
+		return speed * mphToMS
+	}
+	return speed
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWeatherValidator_Validate(t *testing.T) {
+	now := time.Unix(1587646800, 0)
+
+	cases := []struct {
+		units     string
+		dt        int64
+		temp      float64
+		feelsLike *float64
+		timezone  int
+		wantErrIs error
+	}{
+		{"metric", now.Unix() - 600, 9.07, float64Ptr(6.24), 7200, nil},
+		{"metric", 0, 9.07, nil, 0, nil},
+		{"metric", now.Unix(), 2, float64Ptr(0), 0, nil},
+		{"metric", now.Unix() - 4*3600, 9.07, float64Ptr(6.24), 7200, ErrStaleObservation},
+		{"metric", now.Unix() + 3600, 9.07, float64Ptr(6.24), 7200, ErrImplausibleWeather},
+		{"metric", now.Unix(), 120, float64Ptr(6.24), 7200, ErrImplausibleWeather},
+		{"metric", now.Unix(), 9.07, float64Ptr(-150), 7200, ErrImplausibleWeather},
+		{"metric", now.Unix(), 9.07, float64Ptr(6.24), 20 * 3600, ErrImplausibleWeather},
+		{"imperial", now.Unix(), 48.3, float64Ptr(43.2), -18000, nil},
+		{"imperial", now.Unix(), 160, float64Ptr(43.2), -18000, ErrImplausibleWeather},
+		{"standard", now.Unix(), 282.22, float64Ptr(279.39), 0, nil},
+		{"standard", now.Unix(), 282.22, float64Ptr(0), 0, ErrImplausibleWeather},
+		{"standard", now.Unix(), 9.07, nil, 0, ErrImplausibleWeather},
+	}
+
+	for n, tc := range cases {
+		t.Run(fmt.Sprintf("case=%d", n), func(t *testing.T) {
+			var wr WeatherResponse
+			wr.Dt = tc.dt
+			wr.Main.Temp = tc.temp
+			wr.Main.FeelsLike = tc.feelsLike
+			wr.Timezone = tc.timezone
+
+			err := NewWeatherValidator(3*time.Hour, tc.units).Validate(wr, now)
+			if tc.wantErrIs == nil && err != nil {
+				t.Errorf("WeatherValidator.Validate: unexpected error %v", err)
+			} else if !errors.Is(err, tc.wantErrIs) {
+				t.Errorf("WeatherValidator.Validate: want error %v, got %v", tc.wantErrIs, err)
+			}
+		})
+	}
+}
+
+func TestWeatherValidator_Validate_ranges(t *testing.T) {
+	now := time.Unix(1587646800, 0)
+
+	cases := []struct {
+		units      string
+		humidity   float64
+		pressure   float64
+		wind       float64
+		gust       float64
+		visibility int
+		wantErr    bool
+	}{
+		{"metric", 81, 1019, 4.1, 9.3, 10000, false},
+		{"metric", 0, 0, 0, 0, 0, false},
+		{"metric", 100, 850, 0, 0, 0, false},
+		{"metric", 101, 1019, 4.1, 9.3, 10000, true},
+		{"metric", -1, 1019, 4.1, 9.3, 10000, true},
+		{"metric", 81, 1200, 4.1, 9.3, 10000, true},
+		{"metric", 81, 500, 4.1, 9.3, 10000, true},
+		{"metric", 81, 1019, -4.1, 9.3, 10000, true},
+		{"metric", 81, 1019, 4.1, -9.3, 10000, true},
+		{"metric", 81, 1019, 4.1, 9.3, -1, true},
+		{"metric", 81, 1019, 113, 113, 100000, false},
+		{"metric", 81, 1019, 120, 9.3, 10000, true},
+		{"metric", 81, 1019, 4.1, 116, 10000, true},
+		{"metric", 81, 1019, 4.1, 9.3, 100001, true},
+		{"imperial", 81, 1019, 250, 250, 10000, false},
+		{"imperial", 81, 1019, 260, 9.3, 10000, true},
+	}
+
+	for n, tc := range cases {
+		t.Run(fmt.Sprintf("case=%d", n), func(t *testing.T) {
+			var wr WeatherResponse
+			wr.Dt = now.Unix()
+			wr.Main.Temp = 9.07
+			wr.Main.Humidity = tc.humidity
+			wr.Main.Pressure = tc.pressure
+			wr.Wind.Speed = tc.wind
+			wr.Wind.Gust = tc.gust
+			wr.Visibility = tc.visibility
+
+			err := NewWeatherValidator(3*time.Hour, tc.units).Validate(wr, now)
+			if tc.wantErr && !errors.Is(err, ErrImplausibleWeather) {
+				t.Errorf("WeatherValidator.Validate: want error %v, got %v", ErrImplausibleWeather, err)
+			} else if !tc.wantErr && err != nil {
+				t.Errorf("WeatherValidator.Validate: unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestOwmUnits(t *testing.T) {
+	cases := []struct {
+		endpoint string
+		want     string
+	}{
+		{defaultOWMAPIEndpoint, "metric"},
+		{"https://api.openweathermap.org/data/2.5/weather?appid={api-key}&units=imperial", "imperial"},
+		{"https://api.openweathermap.org/data/2.5/weather?appid={api-key}", "standard"},
+	}
+
+	for n, tc := range cases {
+		t.Run(fmt.Sprintf("case=%d", n), func(t *testing.T) {
+			if got := owmUnits(tc.endpoint); got != tc.want {
+				t.Errorf("owmUnits: want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestOWMClient_Weather_validation(t *testing.T) {
+	now := time.Now()
+	good := fmt.Sprintf(`{"cod":200,"name":"Berlin","dt":%d,"weather":[{"id":800,"icon":"01d"}],"main":{"temp":9.07}}`, now.Unix())
+	stale := fmt.Sprintf(`{"cod":200,"name":"Berlin","dt":%d,"weather":[{"id":800,"icon":"01d"}],"main":{"temp":11.2}}`, now.Add(-5*time.Hour).Unix())
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(stale))
+	}))
+	defer ts.Close()
+
+	owm := NewOWMClient(ts.URL+"/weather?appid={api-key}&units=metric", "key", nil)
+	owm.validator = NewWeatherValidator(3*time.Hour, "metric")
+
+	if _, err := owm.Weather(context.Background(), "Berlin,DE"); !errors.Is(err, ErrStaleObservation) {
+		t.Fatalf("OWMClient.Weather: want ErrStaleObservation, got %v", err)
+	}
+
+	// The stale observation is rejected in favor of the expired cache entry.
+	owm.cache = NewWeatherCache(t.TempDir(), time.Hour)
+	if err := owm.cache.Put("owm", owm.cacheKey, "Berlin,DE", http.Header{"Cache-Control": {"max-age=0"}}, []byte(good), now); err != nil {
+		t.Fatalf("WeatherCache.Put: %v", err)
+	}
+	wr, err := owm.Weather(context.Background(), "Berlin,DE")
+	if err != nil {
+		t.Fatalf("OWMClient.Weather: want cached weather, got %v", err)
+	}
+	if want := 9.07; wr.Main.Temp != want {
+		t.Errorf("OWMClient.Weather: want temperature %v, got %v", want, wr.Main.Temp)
+	}
+	if e, _ := owm.cache.Get("owm", owm.cacheKey, "Berlin,DE"); e == nil || e.FetchedAt.Unix() != now.Unix() {
+		t.Errorf("WeatherCache.Get: want the rejected weather not cached, got %+v", e)
+	}
+}
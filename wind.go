@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// The wind segments of the status message.
+const (
+	windSegmentBeaufort = "beaufort" // the force number, e.g. "5 Bft"
+	windSegmentForce    = "force"    // the force name, e.g. "fresh breeze"
+	windSegmentArrow    = "arrow"    // the direction the wind blows to, e.g. "↗"
+	windSegmentGust     = "gust"     // the gusts, if they're strong, e.g. "(gusts 9 Bft)"
+)
+
+const (
+	// defaultWindyBeaufort is the wind force, from which the windy emoji replaces the weather's: a gale.
+	defaultWindyBeaufort = 8
+	// defaultGustBeaufort is the gusts force, worth a warning.
+	defaultGustBeaufort = 8
+
+	mphToMS = 0.44704
+)
+
+// beaufortLimits are the lowest wind speeds of the Beaufort numbers 1 to 12, m/s, as WMO defines them.
+var beaufortLimits = []float64{0.5, 1.6, 3.4, 5.5, 8.0, 10.8, 13.9, 17.2, 20.8, 24.5, 28.5, 32.7}
+
+var beaufortNames = []string{
+	"calm",
+	"light air",
+	"light breeze",
+	"gentle breeze",
+	"moderate breeze",
+	"fresh breeze",
+	"strong breeze",
+	"near gale",
+	"gale",
+	"strong gale",
+	"storm",
+	"violent storm",
+	"hurricane force",
+}
+
+// Beaufort returns the Beaufort number of the wind speed, m/s.
+func Beaufort(speed float64) int {
+	for n, limit := range beaufortLimits {
+		if speed < limit {
+			return n
+		}
+	}
+	return len(beaufortLimits)
+}
+
+// BeaufortName returns the name of the wind force, e.g. "fresh breeze".
+func BeaufortName(n int) string {
+	if n < 0 || n >= len(beaufortNames) {
+		return ""
+	}
+	return beaufortNames[n]
+}
+
+// windArrow returns the arrow, pointing where the wind, blowing from the direction deg, goes.
+func windArrow(deg float64) string {
+	arrows := []string{"↓", "↙", "←", "↖", "↑", "↗", "→", "↘"}
+	sector := int(math.Round(math.Mod(deg, 360)/45)) % len(arrows)
+	if sector < 0 {
+		sector += len(arrows)
+	}
+	return arrows[sector]
+}
+
+// metersPerSecond converts the wind speed in the response's units to m/s.
+func (wr WeatherResponse) metersPerSecond(speed float64) float64 {
+	if wr.Units == "imperial" {
+		return speed * mphToMS
+	}
+	return speed
+}
+
+// WindBeaufort returns the Beaufort number of the wind.
+func (wr WeatherResponse) WindBeaufort() int {
+	return Beaufort(wr.metersPerSecond(wr.Wind.Speed))
+}
+
+// GustBeaufort returns the Beaufort number of the gusts, or zero, if they're not reported.
+func (wr WeatherResponse) GustBeaufort() int {
+	return Beaufort(wr.metersPerSecond(wr.Wind.Gust))
+}
+
+// windSegments formats the wind segments, enabled in the format. The direction and the force are omitted,
+// when it's calm, and the gusts, when they're weaker than the format's gust level.
+func (wr WeatherResponse) windSegments(f statusFormat) []string {
+	force := wr.WindBeaufort()
+	var segments []string
+	for _, name := range f.WindSegments {
+		switch name {
+		case windSegmentBeaufort:
+			if force > 0 {
+				segments = append(segments, strconv.Itoa(force)+" Bft")
+			}
+		case windSegmentForce:
+			if force > 0 {
+				segments = append(segments, BeaufortName(force))
+			}
+		case windSegmentArrow:
+			if force > 0 {
+				segments = append(segments, windArrow(wr.Wind.Deg))
+			}
+		case windSegmentGust:
+			if gust := wr.GustBeaufort(); gust >= f.gustBeaufort() && gust > force {
+				segments = append(segments, fmt.Sprintf("(gusts %d Bft)", gust))
+			}
+		}
+	}
+	return segments
+}
+
+// windy reports whether the wind is strong enough to replace the weather's emoji.
+func (wr WeatherResponse) windy(f statusFormat) bool {
+	return wr.WindBeaufort() >= f.windyBeaufort()
+}
+
+func validateWindSegments(segments []string) error {
+	for _, s := range segments {
+		switch s {
+		case windSegmentBeaufort, windSegmentForce, windSegmentArrow, windSegmentGust:
+		default:
+			return fmt.Errorf("unknown wind segment %q, expected one of %s", s,
+				strings.Join([]string{windSegmentBeaufort, windSegmentForce, windSegmentArrow, windSegmentGust}, ", "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBeaufort(t *testing.T) {
+	cases := []struct {
+		speed float64
+		want  int
+		name  string
+	}{
+		{0, 0, "calm"},
+		{0.5, 1, "light air"},
+		{1.5, 1, "light air"},
+		{3.2, 2, "light breeze"},
+		{3.3, 2, "light breeze"},
+		{5.4, 3, "gentle breeze"},
+		{7.9, 4, "moderate breeze"},
+		{10.7, 5, "fresh breeze"},
+		{13.8, 6, "strong breeze"},
+		{13.9, 7, "near gale"},
+		{17.1, 7, "near gale"},
+		{20.6, 8, "gale"},
+		{24.5, 10, "storm"},
+		{32.6, 11, "violent storm"},
+		{32.7, 12, "hurricane force"},
+		{45, 12, "hurricane force"},
+	}
+
+	for n, tc := range cases {
+		t.Run(fmt.Sprintf("case=%d", n), func(t *testing.T) {
+			got := Beaufort(tc.speed)
+			if got != tc.want {
+				t.Errorf("Beaufort(%v): want %d, got %d", tc.speed, tc.want, got)
+			}
+			if name := BeaufortName(got); name != tc.name {
+				t.Errorf("BeaufortName(%d): want %q, got %q", got, tc.name, name)
+			}
+		})
+	}
+}
+
+func TestWindArrow(t *testing.T) {
+	cases := []struct {
+		deg  float64
+		want string
+	}{
+		{0, "↓"},
+		{22, "↓"},
+		{23, "↙"},
+		{90, "←"},
+		{225, "↗"},
+		{250, "→"},
+		{337.6, "↓"},
+		{360, "↓"},
+	}
+
+	for n, tc := range cases {
+		t.Run(fmt.Sprintf("case=%d", n), func(t *testing.T) {
+			if got := windArrow(tc.deg); got != tc.want {
+				t.Errorf("windArrow(%v): want %q, got %q", tc.deg, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestWeatherResponse_wind(t *testing.T) {
+	cases := []struct {
+		units     string
+		speed     float64
+		gust      float64
+		format    statusFormat
+		wantMsg   string
+		wantEmoji string
+	}{
+		{"", 4.1, 8.7, statusFormat{}, "Berlin, +9°", ":sunny:"},
+		{"", 4.1, 8.7, statusFormat{WindSegments: []string{"arrow", "beaufort", "gust"}}, "Berlin, +9° → 3 Bft", ":sunny:"},
+		{"", 9.8, 18.2, statusFormat{WindSegments: []string{"arrow", "force", "gust"}}, "Berlin, +9° → fresh breeze (gusts 8 Bft)", ":sunny:"},
+		{"", 9.8, 12, statusFormat{WindSegments: []string{"gust"}, GustBeaufort: 6}, "Berlin, +9° (gusts 6 Bft)", ":sunny:"},
+		{"", 0.2, 0, statusFormat{WindSegments: []string{"arrow", "beaufort"}}, "Berlin, +9°", ":sunny:"},
+		{"", 22.4, 30, statusFormat{WindSegments: []string{"beaufort"}}, "Berlin, +9° 9 Bft", ":dash:"},
+		{"", 22.4, 30, statusFormat{WindyBeaufort: 13}, "Berlin, +9°", ":sunny:"},
+		{"", 11, 0, statusFormat{WindyBeaufort: 6}, "Berlin, +9°", ":dash:"},
+		{"imperial", 22.4, 0, statusFormat{WindSegments: []string{"beaufort"}}, "Berlin, +9° 5 Bft", ":sunny:"},
+	}
+
+	for n, tc := range cases {
+		t.Run(fmt.Sprintf("case=%d", n), func(t *testing.T) {
+			var wr WeatherResponse
+			wr.Name = "Berlin"
+			wr.Units = tc.units
+			wr.Main.Temp = 9.07
+			wr.Wind.Speed = tc.speed
+			wr.Wind.Gust = tc.gust
+			wr.Wind.Deg = 250
+			wr.Weather = append(wr.Weather, struct {
+				ID          int    `json:"id"`
+				Main        string `json:"main"`
+				Description string `json:"description"`
+				Icon        string `json:"icon"`
+			}{ID: 800, Main: "Clear", Icon: "01d"})
+
+			if got := wr.shortString(tc.format); got != tc.wantMsg {
+				t.Errorf("WeatherResponse.shortString: want %q, got %q", tc.wantMsg, got)
+			}
+			if got := wr.emoji(tc.format); got != tc.wantEmoji {
+				t.Errorf("WeatherResponse.emoji: want %q, got %q", tc.wantEmoji, got)
+			}
+		})
+	}
+}